@@ -0,0 +1,6 @@
+// +k8s:deepcopy-gen=package
+// +groupName=kube-flux.io
+// +groupGoName=Kubeflux
+
+// Package v1alpha1 contains the v1alpha1 version of the kube-flux.io API group.
+package v1alpha1
@@ -0,0 +1,126 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionApplied is the condition type reporting whether the controller applied the policy.
+const ConditionApplied = "Applied"
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// EnergyPolicy is the energy status of a namespace together with the replica factors
+// the controller applies to every importance class in each status.
+type EnergyPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EnergyPolicySpec   `json:"spec"`
+	Status EnergyPolicyStatus `json:"status,omitempty"`
+}
+
+// EnergyPolicySpec is the desired energy state.
+type EnergyPolicySpec struct {
+	// Status is the current energy status: Green, Brown or Black.
+	Status string `json:"status"`
+	// Factors maps every energy status to the replica count of each importance class.
+	// +optional
+	Factors map[string]map[string]int32 `json:"factors,omitempty"`
+	// Percentages maps energy statuses to the share of the baseline replicas of each importance
+	// class. They take precedence over Factors for the classes they define.
+	// +optional
+	Percentages map[string]map[string]ReplicaPercentage `json:"percentages,omitempty"`
+	// Thresholds are the usage bands used to pick the scaling direction with the default rules.
+	// Deprecated: use Rules.
+	// +optional
+	Thresholds *Thresholds `json:"thresholds,omitempty"`
+	// Rules map the usage of the importance classes to the replica targets of every energy status.
+	// They replace the rules of the controller's -scaling-rules file.
+	// +optional
+	Rules []ScalingRule `json:"rules,omitempty"`
+}
+
+// ReplicaPercentage sets the replicas of every workload of an importance class to a share of
+// the replicas it had before the first energy-driven change.
+type ReplicaPercentage struct {
+	// Percent of the baseline replicas, rounded up, between 0 and 100.
+	Percent int32 `json:"percent"`
+	// Min is the lowest replica count of every workload of the class.
+	// +optional
+	Min *int32 `json:"min,omitempty"`
+	// Max is the highest replica count of every workload of the class.
+	// +optional
+	Max *int32 `json:"max,omitempty"`
+}
+
+// ScalingRule is the replica target of an importance class in an energy status.
+type ScalingRule struct {
+	// Status is the energy status the rule applies to.
+	Status string `json:"status"`
+	// Class is the importance class the rule applies to.
+	Class string `json:"class"`
+	// UsageClass is the class whose average usage is compared to the bands, Class when empty.
+	// +optional
+	UsageClass string `json:"usageClass,omitempty"`
+	// MinReplicas is the lowest replica count of the class.
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the highest replica count of the class.
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// Bands are evaluated in order, the first one matching the usage sets the target.
+	// +optional
+	Bands []UsageBand `json:"bands,omitempty"`
+}
+
+// UsageBand maps a range of the average usage of a pod to a replica target.
+type UsageBand struct {
+	// +optional
+	CPUAbove *resource.Quantity `json:"cpuAbove,omitempty"`
+	// +optional
+	CPUBelow *resource.Quantity `json:"cpuBelow,omitempty"`
+	// +optional
+	MemoryAbove *resource.Quantity `json:"memoryAbove,omitempty"`
+	// +optional
+	MemoryBelow *resource.Quantity `json:"memoryBelow,omitempty"`
+	// Replicas is the target of the band.
+	Replicas int32 `json:"replicas"`
+	// Action is "add" to only scale up, "subtract" to only scale down, or empty.
+	// +optional
+	Action string `json:"action,omitempty"`
+}
+
+// Thresholds are the average CPU usage bands of the High importance class, in nanocores.
+type Thresholds struct {
+	// ScaleDownCPU is the usage above which replicas are only scaled down.
+	// +optional
+	ScaleDownCPU int64 `json:"scaleDownCPU,omitempty"`
+	// ScaleUpCPU is the usage below which replicas are only scaled up.
+	// +optional
+	ScaleUpCPU int64 `json:"scaleUpCPU,omitempty"`
+}
+
+// EnergyPolicyStatus is the state of the policy as last observed by the controller.
+type EnergyPolicyStatus struct {
+	// ObservedGeneration is the generation of the spec the controller last applied.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastAppliedTime is when the controller last applied the spec.
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+	// Conditions are the latest observations of the policy state.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// EnergyPolicyList is a list of EnergyPolicy objects.
+type EnergyPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []EnergyPolicy `json:"items"`
+}
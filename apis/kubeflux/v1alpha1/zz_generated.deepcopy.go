@@ -0,0 +1,288 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright The kube-flux Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by deepcopy-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnergyPolicy) DeepCopyInto(out *EnergyPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnergyPolicy.
+func (in *EnergyPolicy) DeepCopy() *EnergyPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(EnergyPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EnergyPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnergyPolicyList) DeepCopyInto(out *EnergyPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EnergyPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnergyPolicyList.
+func (in *EnergyPolicyList) DeepCopy() *EnergyPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(EnergyPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EnergyPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnergyPolicySpec) DeepCopyInto(out *EnergyPolicySpec) {
+	*out = *in
+	if in.Factors != nil {
+		in, out := &in.Factors, &out.Factors
+		*out = make(map[string]map[string]int32, len(*in))
+		for key, val := range *in {
+			var outVal map[string]int32
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]int32, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Percentages != nil {
+		in, out := &in.Percentages, &out.Percentages
+		*out = make(map[string]map[string]ReplicaPercentage, len(*in))
+		for key, val := range *in {
+			var outVal map[string]ReplicaPercentage
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]ReplicaPercentage, len(*in))
+				for key, val := range *in {
+					(*out)[key] = *val.DeepCopy()
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Thresholds != nil {
+		in, out := &in.Thresholds, &out.Thresholds
+		*out = new(Thresholds)
+		**out = **in
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]ScalingRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnergyPolicySpec.
+func (in *EnergyPolicySpec) DeepCopy() *EnergyPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EnergyPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnergyPolicyStatus) DeepCopyInto(out *EnergyPolicyStatus) {
+	*out = *in
+	if in.LastAppliedTime != nil {
+		in, out := &in.LastAppliedTime, &out.LastAppliedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnergyPolicyStatus.
+func (in *EnergyPolicyStatus) DeepCopy() *EnergyPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EnergyPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaPercentage) DeepCopyInto(out *ReplicaPercentage) {
+	*out = *in
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaPercentage.
+func (in *ReplicaPercentage) DeepCopy() *ReplicaPercentage {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaPercentage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScalingRule) DeepCopyInto(out *ScalingRule) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Bands != nil {
+		in, out := &in.Bands, &out.Bands
+		*out = make([]UsageBand, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScalingRule.
+func (in *ScalingRule) DeepCopy() *ScalingRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ScalingRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Thresholds) DeepCopyInto(out *Thresholds) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Thresholds.
+func (in *Thresholds) DeepCopy() *Thresholds {
+	if in == nil {
+		return nil
+	}
+	out := new(Thresholds)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UsageBand) DeepCopyInto(out *UsageBand) {
+	*out = *in
+	if in.CPUAbove != nil {
+		in, out := &in.CPUAbove, &out.CPUAbove
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.CPUBelow != nil {
+		in, out := &in.CPUBelow, &out.CPUBelow
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MemoryAbove != nil {
+		in, out := &in.MemoryAbove, &out.MemoryAbove
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MemoryBelow != nil {
+		in, out := &in.MemoryBelow, &out.MemoryBelow
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UsageBand.
+func (in *UsageBand) DeepCopy() *UsageBand {
+	if in == nil {
+		return nil
+	}
+	out := new(UsageBand)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,73 @@
+package crd_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/apis/kubeflux/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// customResourceDefinition holds the fields of a CustomResourceDefinition the controller relies on.
+type customResourceDefinition struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind   string `json:"kind"`
+			Plural string `json:"plural"`
+		} `json:"names"`
+		Versions []struct {
+			Name   string `json:"name"`
+			Schema struct {
+				OpenAPIV3Schema map[string]interface{} `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+func TestEnergyPolicyCRD(t *testing.T) {
+	data, err := ioutil.ReadFile("energypolicy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var crd customResourceDefinition
+	if err := yaml.Unmarshal(data, &crd); err != nil {
+		t.Fatalf("energypolicy.yaml isn't valid YAML: %v", err)
+	}
+	if crd.APIVersion != "apiextensions.k8s.io/v1" || crd.Kind != "CustomResourceDefinition" {
+		t.Errorf("got %s %s, want apiextensions.k8s.io/v1 CustomResourceDefinition", crd.APIVersion, crd.Kind)
+	}
+	if crd.Spec.Group != v1alpha1.SchemeGroupVersion.Group || crd.Spec.Names.Kind != "EnergyPolicy" || crd.Spec.Names.Plural != "energypolicies" {
+		t.Errorf("got group %s, kind %s and plural %s, want the EnergyPolicy of %s", crd.Spec.Group, crd.Spec.Names.Kind, crd.Spec.Names.Plural, v1alpha1.SchemeGroupVersion.Group)
+	}
+	found := false
+	for _, version := range crd.Spec.Versions {
+		if version.Name != v1alpha1.SchemeGroupVersion.Version {
+			continue
+		}
+		found = true
+		if version.Schema.OpenAPIV3Schema == nil {
+			t.Errorf("version %s has no openAPIV3Schema", version.Name)
+		}
+	}
+	if !found {
+		t.Errorf("version %s isn't defined", v1alpha1.SchemeGroupVersion.Version)
+	}
+}
+
+func TestDefaultEnergyPolicy(t *testing.T) {
+	data, err := ioutil.ReadFile("default.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ep v1alpha1.EnergyPolicy
+	// unknown fields would be dropped by the API server
+	if err := yaml.UnmarshalStrict(data, &ep); err != nil {
+		t.Fatalf("default.yaml isn't a valid EnergyPolicy: %v", err)
+	}
+	if ep.Kind != "EnergyPolicy" || ep.Spec.Status == "" {
+		t.Errorf("got kind %q and status %q, want an EnergyPolicy with a status", ep.Kind, ep.Spec.Status)
+	}
+}
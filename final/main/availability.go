@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/kube-flux/kube-flux/scaling"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// minReplicasAnnotation is the lowest replica count of a workload, e.g. required by its SLO.
+const minReplicasAnnotation = "kube-flux.io/min-replicas"
+
+// reasonTargetUnmet is the reason of the Event recorded when availability holds a scale-down.
+const reasonTargetUnmet = "TargetUnmet"
+
+// UseDisruptionBudgets makes scale-downs respect the PodDisruptionBudgets covering the pods of
+// a workload. It must be called before the factory is started.
+func (c *Controller) UseDisruptionBudgets(factory informers.SharedInformerFactory) {
+	informer := factory.Policy().V1().PodDisruptionBudgets()
+	c.pdbLister = informer.Lister()
+	c.informersSynced = append(c.informersSynced, informer.Informer().HasSynced)
+	// a changed budget may hold or release scale-downs of any class
+	enqueueAll := func(interface{}) { c.enqueueAll() }
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueueAll,
+		UpdateFunc: func(_, newObj interface{}) { enqueueAll(newObj) },
+		DeleteFunc: enqueueAll,
+	})
+}
+
+// availabilityFloor returns the lowest replicas a workload expected to run expected replicas may
+// be scaled down to, along with the constraint setting it, empty when nothing does:
+//   - the kube-flux.io/min-replicas annotation of the workload
+//   - the minAvailable of a PodDisruptionBudget selecting its pods
+//   - expected minus the maxUnavailable of such a budget
+//
+// Percentages of a budget are relative to expected and rounded up, like the disruption controller
+// does with the scale of the owner of the pods.
+func (c *Controller) availabilityFloor(w *scaling.Workload, expected int32) (int32, string, error) {
+	var floor int32
+	var constraint string
+	if value, ok := w.GetAnnotations()[minReplicasAnnotation]; ok {
+		minReplicas, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid %s annotation of %s: %v", minReplicasAnnotation, w.Key(), err)
+		}
+		floor, constraint = int32(minReplicas), fmt.Sprintf("%s %d", minReplicasAnnotation, minReplicas)
+	}
+	if c.pdbLister == nil {
+		return floor, constraint, nil
+	}
+
+	pdbs, err := c.pdbLister.PodDisruptionBudgets(w.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return 0, "", err
+	}
+	podLabels := labels.Set(w.TemplateLabels())
+	for _, pdb := range pdbs {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		// an empty selector of a policy/v1 budget selects every pod of the namespace, a nil one none
+		if err != nil || !selector.Matches(podLabels) {
+			continue
+		}
+		var pdbFloor int32
+		var field string
+		switch {
+		case pdb.Spec.MinAvailable != nil:
+			minAvailable, err := intstr.GetValueFromIntOrPercent(pdb.Spec.MinAvailable, int(expected), true)
+			if err != nil {
+				return 0, "", fmt.Errorf("invalid minAvailable of PodDisruptionBudget %s/%s: %v", pdb.GetNamespace(), pdb.GetName(), err)
+			}
+			pdbFloor, field = int32(minAvailable), "minAvailable "+pdb.Spec.MinAvailable.String()
+		case pdb.Spec.MaxUnavailable != nil:
+			maxUnavailable, err := intstr.GetValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(expected), true)
+			if err != nil {
+				return 0, "", fmt.Errorf("invalid maxUnavailable of PodDisruptionBudget %s/%s: %v", pdb.GetNamespace(), pdb.GetName(), err)
+			}
+			pdbFloor, field = expected-int32(maxUnavailable), "maxUnavailable "+pdb.Spec.MaxUnavailable.String()
+		default:
+			continue
+		}
+		if pdbFloor > floor {
+			floor, constraint = pdbFloor, fmt.Sprintf("PodDisruptionBudget %s (%s)", pdb.GetName(), field)
+		}
+	}
+	return floor, constraint, nil
+}
+
+// holdForAvailability raises the desired replicas of a scale-down to the availability floor of
+// the workload, marking the change as unmet when the floor is above the target.
+func (c *Controller) holdForAvailability(change *plannedChange) error {
+	if change.Desired >= change.Current {
+		return nil
+	}
+	// the workload is expected to run its baseline; relative to the current replicas, the floor
+	// would go down with every scale-down
+	expected := change.workload.Replicas()
+	if change.Baseline != nil {
+		expected = *change.Baseline
+	}
+	floor, constraint, err := c.availabilityFloor(change.workload, expected)
+	if err != nil {
+		return err
+	}
+	if change.Desired >= floor {
+		return nil
+	}
+	if floor > change.Current {
+		floor = change.Current
+	}
+	target := change.Desired
+	change.Reason += fmt.Sprintf(", held at %d instead of %d by %s", floor, target, constraint)
+	change.UnmetTarget = &target
+	change.Desired = floor
+	return nil
+}
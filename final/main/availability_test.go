@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kube-flux/kube-flux/scaling"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	policylisters "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// budget returns a PodDisruptionBudget of namespace shop selecting the pods labeled app=web.
+func budget(name string, minAvailable, maxUnavailable *intstr.IntOrString) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			MinAvailable:   minAvailable,
+			MaxUnavailable: maxUnavailable,
+		},
+	}
+}
+
+func intOrString(value string) *intstr.IntOrString {
+	v := intstr.Parse(value)
+	return &v
+}
+
+func TestAvailabilityFloor(t *testing.T) {
+	otherSelector := budget("api", intOrString("9"), nil)
+	otherSelector.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+	otherNamespace := budget("web", intOrString("9"), nil)
+	otherNamespace.Namespace = "blog"
+	emptySelector := budget("all", intOrString("6"), nil)
+	emptySelector.Spec.Selector = &metav1.LabelSelector{}
+	nilSelector := budget("none", intOrString("9"), nil)
+	nilSelector.Spec.Selector = nil
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		budgets     []*policyv1.PodDisruptionBudget
+		// noBudgets leaves PodDisruptionBudgets unwatched
+		noBudgets      bool
+		expected       int32
+		want           int32
+		wantConstraint string
+		wantErr        bool
+	}{
+		{name: "nothing", expected: 10},
+		{name: "unwatched budgets", noBudgets: true, budgets: []*policyv1.PodDisruptionBudget{budget("web", intOrString("4"), nil)}, expected: 10},
+		{name: "annotation", annotations: map[string]string{minReplicasAnnotation: "3"}, expected: 10, want: 3, wantConstraint: "kube-flux.io/min-replicas 3"},
+		{name: "invalid annotation", annotations: map[string]string{minReplicasAnnotation: "three"}, expected: 10, wantErr: true},
+		{
+			name:     "minAvailable",
+			budgets:  []*policyv1.PodDisruptionBudget{budget("web", intOrString("4"), nil)},
+			expected: 10, want: 4, wantConstraint: "PodDisruptionBudget web (minAvailable 4)",
+		},
+		{
+			name:     "minAvailable percent",
+			budgets:  []*policyv1.PodDisruptionBudget{budget("web", intOrString("50%"), nil)},
+			expected: 5, want: 3, wantConstraint: "PodDisruptionBudget web (minAvailable 50%)",
+		},
+		{
+			name:     "maxUnavailable",
+			budgets:  []*policyv1.PodDisruptionBudget{budget("web", nil, intOrString("2"))},
+			expected: 10, want: 8, wantConstraint: "PodDisruptionBudget web (maxUnavailable 2)",
+		},
+		{
+			name:     "maxUnavailable percent",
+			budgets:  []*policyv1.PodDisruptionBudget{budget("web", nil, intOrString("25%"))},
+			expected: 10, want: 7, wantConstraint: "PodDisruptionBudget web (maxUnavailable 25%)",
+		},
+		{
+			name:     "invalid percent",
+			budgets:  []*policyv1.PodDisruptionBudget{budget("web", intOrString("half%"), nil)},
+			expected: 10, wantErr: true,
+		},
+		{
+			name:        "highest floor",
+			annotations: map[string]string{minReplicasAnnotation: "6"},
+			budgets: []*policyv1.PodDisruptionBudget{
+				budget("web", intOrString("4"), nil),
+				budget("web-strict", nil, intOrString("3")),
+			},
+			expected: 10, want: 7, wantConstraint: "PodDisruptionBudget web-strict (maxUnavailable 3)",
+		},
+		{
+			name:        "annotation above budgets",
+			annotations: map[string]string{minReplicasAnnotation: "6"},
+			budgets:     []*policyv1.PodDisruptionBudget{budget("web", intOrString("4"), nil)},
+			expected:    10, want: 6, wantConstraint: "kube-flux.io/min-replicas 6",
+		},
+		{
+			name:     "other pods",
+			budgets:  []*policyv1.PodDisruptionBudget{otherSelector, otherNamespace, nilSelector},
+			expected: 10,
+		},
+		{
+			name:     "empty selector",
+			budgets:  []*policyv1.PodDisruptionBudget{emptySelector},
+			expected: 10, want: 6, wantConstraint: "PodDisruptionBudget all (minAvailable 6)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Controller{}
+			if !test.noBudgets {
+				indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+				for _, pdb := range test.budgets {
+					if err := indexer.Add(pdb); err != nil {
+						t.Fatal(err)
+					}
+				}
+				c.pdbLister = policylisters.NewPodDisruptionBudgetLister(indexer)
+			}
+
+			u := &unstructured.Unstructured{}
+			u.SetAPIVersion("apps/v1")
+			u.SetKind("Deployment")
+			u.SetNamespace("shop")
+			u.SetName("web")
+			u.SetAnnotations(test.annotations)
+			if err := unstructured.SetNestedStringMap(u.Object, map[string]string{"app": "web"}, "spec", "template", "metadata", "labels"); err != nil {
+				t.Fatal(err)
+			}
+			w := &scaling.Workload{Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, Unstructured: u}
+
+			floor, constraint, err := c.availabilityFloor(w, test.expected)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got floor %d (%s), want an error", floor, constraint)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if floor != test.want || constraint != test.wantConstraint {
+				t.Errorf("got floor %d (%q), want %d (%q)", floor, constraint, test.want, test.wantConstraint)
+			}
+		})
+	}
+}
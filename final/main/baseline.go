@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	// baselineAnnotation keeps the replicas a workload had before the first energy-driven change.
+	baselineAnnotation = "kube-flux.io/baseline-replicas"
+	// releasedAnnotation marks the workloads handed back to their operators through the release API.
+	releasedAnnotation = "kube-flux.io/released"
+)
+
+// reasonReleased is the reason of the Event recorded on released workloads.
+const reasonReleased = "Released"
+
+// baselineOf returns the baseline replicas recorded on a workload.
+func baselineOf(w *scaling.Workload) (int32, bool) {
+	value, ok := w.GetAnnotations()[baselineAnnotation]
+	if !ok {
+		return 0, false
+	}
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || replicas < 0 {
+		log.Println("func", "baselineOf", "Ignoring invalid", baselineAnnotation, "of", w.Key(), "err:", err)
+		return 0, false
+	}
+	return int32(replicas), true
+}
+
+// released reports whether a workload was handed back through the release API.
+func released(w *scaling.Workload) bool {
+	_, ok := w.GetAnnotations()[releasedAnnotation]
+	return ok
+}
+
+// recordBaseline records the current replicas of a workload as its baseline unless it has one.
+// The patch fails on a stale cache, so that replicas changed by the controller are never taken
+// for the baseline.
+func (c *Controller) recordBaseline(w *scaling.Workload) error {
+	if _, ok := baselineOf(w); ok {
+		return nil
+	}
+	return c.annotateWorkload(w, w.GetResourceVersion(), map[string]interface{}{
+		baselineAnnotation: strconv.Itoa(int(w.Replicas())),
+	})
+}
+
+// dropBaseline removes the baseline of a workload once it was restored.
+func (c *Controller) dropBaseline(w *scaling.Workload) error {
+	return c.annotateWorkload(w, "", map[string]interface{}{baselineAnnotation: nil})
+}
+
+// annotateWorkload patches the annotations of a workload, a nil value removes one.
+func (c *Controller) annotateWorkload(w *scaling.Workload, resourceVersion string, annotations map[string]interface{}) error {
+	patch, err := mergePatch(resourceVersion, annotations, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.dynamicClient.Resource(w.Resource).Namespace(w.GetNamespace()).Patch(context.TODO(), w.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// setReleasing marks a release of a workload as in flight, or as failed.
+func (c *Controller) setReleasing(w *scaling.Workload, releasing bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !releasing {
+		delete(c.releasing, w.Key())
+		return
+	}
+	if c.releasing == nil {
+		c.releasing = make(map[string]bool)
+	}
+	c.releasing[w.Key()] = true
+}
+
+// isReleasing reports whether a release of a workload is in flight. It ends once the informer
+// cache shows the workload released.
+func (c *Controller) isReleasing(w *scaling.Workload) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if released(w) {
+		delete(c.releasing, w.Key())
+		return false
+	}
+	return c.releasing[w.Key()]
+}
+
+// workloadStatus is a workload as listed and released by the release API.
+type workloadStatus struct {
+	Namespace string       `json:"namespace"`
+	Kind      string       `json:"kind"`
+	Name      string       `json:"name"`
+	Class     policy.Class `json:"class"`
+	Replicas  int32        `json:"replicas"`
+	Baseline  *int32       `json:"baseline,omitempty"`
+	Released  bool         `json:"released"`
+}
+
+// managedWorkloads returns the workloads with an importance class matching namespace, kind and
+// name, case-insensitive for kind; empty values match every workload.
+func (c *Controller) managedWorkloads(namespace, kind, name string) ([]*scaling.Workload, error) {
+	workloads, err := c.listManagedWorkloads()
+	if err != nil {
+		return nil, err
+	}
+	classes := groupWorkloads(workloads)
+	var matching []*scaling.Workload
+	for _, class := range policy.Classes {
+		for _, w := range classes[class] {
+			if (namespace == "" || namespace == w.GetNamespace()) && (kind == "" || strings.EqualFold(kind, w.GetKind())) && (name == "" || name == w.GetName()) {
+				matching = append(matching, w)
+			}
+		}
+	}
+	return matching, nil
+}
+
+// statusOf describes a workload for the release API.
+func statusOf(w *scaling.Workload) workloadStatus {
+	class, _ := workloadImportance(w)
+	status := workloadStatus{
+		Namespace: w.GetNamespace(),
+		Kind:      w.GetKind(),
+		Name:      w.GetName(),
+		Class:     class,
+		Replicas:  w.Replicas(),
+		Released:  released(w),
+	}
+	if baseline, ok := baselineOf(w); ok {
+		status.Baseline = &baseline
+	}
+	return status
+}
+
+// Release hands the matching workloads back to their operators: their baseline replicas, or the
+// original bounds of their HorizontalPodAutoscaler, are restored and the controller leaves them
+// alone until Engage is called. With -dry-run the workloads that would be released are only
+// returned.
+func (c *Controller) Release(namespace, kind, name string) ([]workloadStatus, error) {
+	workloads, err := c.managedWorkloads(namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	releasedWorkloads := []workloadStatus{}
+	var errs []error
+	for _, w := range workloads {
+		if released(w) {
+			continue
+		}
+		status := statusOf(w)
+		if dryRun {
+			if status.Baseline != nil {
+				status.Replicas = *status.Baseline
+			}
+			status.Released = true
+			fmt.Printf("[dry-run] release %s with %d replicas\n", w.Key(), status.Replicas)
+			releasedWorkloads = append(releasedWorkloads, status)
+			continue
+		}
+		// the workers leave the workload alone while it's restored; it's only marked released and
+		// its baseline dropped once restored, so that a failed release keeps the baseline to retry
+		c.setReleasing(w, true)
+		hpa, err := c.autoscalerOf(w)
+		if err == nil && hpa != nil {
+			err = c.restoreAutoscaler(hpa)
+		}
+		if err == nil && status.Baseline != nil && *status.Baseline != status.Replicas {
+			_, err = scaling.SetScale(context.TODO(), c.scales, w.Resource.GroupResource(), w.GetNamespace(), w.GetName(), *status.Baseline)
+		}
+		if err == nil {
+			err = c.annotateWorkload(w, "", map[string]interface{}{releasedAnnotation: "true", baselineAnnotation: nil})
+		}
+		if err != nil {
+			c.setReleasing(w, false)
+			if !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to release %s: %v", w.Key(), err))
+			}
+			continue
+		}
+		c.stabilizer.Forget(w.Key())
+		message := "Released by the release API"
+		if status.Baseline != nil {
+			message = fmt.Sprintf("Released by the release API, baseline of %d replicas restored", *status.Baseline)
+			status.Replicas = *status.Baseline
+		}
+		c.recorder.Event(w, corev1.EventTypeNormal, reasonReleased, message)
+		log.Println("func", "Release", "Released", w.Key())
+		status.Released = true
+		releasedWorkloads = append(releasedWorkloads, status)
+	}
+	return releasedWorkloads, utilerrors.NewAggregate(errs)
+}
+
+// Engage makes the controller manage released workloads matching namespace, kind and name again.
+// With -dry-run the workloads that would be engaged are only returned.
+func (c *Controller) Engage(namespace, kind, name string) ([]workloadStatus, error) {
+	workloads, err := c.managedWorkloads(namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	engaged := []workloadStatus{}
+	var errs []error
+	for _, w := range workloads {
+		if !released(w) {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("[dry-run] engage %s\n", w.Key())
+		} else if err := c.annotateWorkload(w, "", map[string]interface{}{releasedAnnotation: nil}); err != nil {
+			if !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to engage %s: %v", w.Key(), err))
+			}
+			continue
+		}
+		if !dryRun {
+			log.Println("func", "Engage", "Engaged", w.Key())
+		}
+		status := statusOf(w)
+		status.Released = false
+		engaged = append(engaged, status)
+	}
+	c.enqueueAll()
+	return engaged, utilerrors.NewAggregate(errs)
+}
+
+// ReleaseBackend handles the release API on /release: GET lists the managed workloads with their
+// baselines, POST releases the workloads matching the namespace, kind and name query parameters,
+// every workload without any, and DELETE engages them again.
+func ReleaseBackend(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	namespace, kind, name := query.Get("namespace"), query.Get("kind"), query.Get("name")
+	var workloads []workloadStatus
+	var action string
+	var err error
+	switch req.Method {
+	case "GET":
+		action = "list"
+		var matching []*scaling.Workload
+		matching, err = controller.managedWorkloads(namespace, kind, name)
+		workloads = make([]workloadStatus, 0, len(matching))
+		for _, wl := range matching {
+			workloads = append(workloads, statusOf(wl))
+		}
+	case "POST":
+		action = "release"
+		log.Println("func", "ReleaseBackend", "Handling POST request", namespace, kind, name)
+		workloads, err = controller.Release(namespace, kind, name)
+	case "DELETE":
+		action = "engage"
+		log.Println("func", "ReleaseBackend", "Handling DELETE request", namespace, kind, name)
+		workloads, err = controller.Engage(namespace, kind, name)
+	default:
+		w.Header().Set("Allow", "DELETE, GET, OPTIONS, POST")
+		policy.WriteProblem(w, req, http.StatusMethodNotAllowed, "Method "+req.Method+" is not supported.")
+		return
+	}
+	if err != nil {
+		// API server errors may name resources or permissions the caller isn't meant to see
+		log.Println("func", "ReleaseBackend", "err:", err)
+		policy.WriteProblem(w, req, http.StatusInternalServerError, "Failed to "+action+" the workloads.")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workloads)
+}
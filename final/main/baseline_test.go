@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// annotationsOf returns the annotations of the Deployment web served by the dynamic client.
+func annotationsOf(t *testing.T, c *Controller) map[string]string {
+	t.Helper()
+	got, err := c.dynamicClient.Resource(deployments).Namespace("shop").Get(context.TODO(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got.GetAnnotations()
+}
+
+func TestRecordBaseline(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+	}{
+		{name: "first change", want: "4"},
+		{name: "recorded", annotations: map[string]string{baselineAnnotation: "9"}, want: "9"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := workload(deployments, "web", policy.Low, 4, test.annotations)
+			c, _ := newTestController(t, nil, obj)
+			w, _ := scaling.NewWorkload(deployments, obj)
+
+			if err := c.recordBaseline(w); err != nil {
+				t.Fatal(err)
+			}
+			if got := annotationsOf(t, c)[baselineAnnotation]; got != test.want {
+				t.Errorf("got baseline %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReleaseEngage(t *testing.T) {
+	defer func(d bool) { dryRun = d }(dryRun)
+	tests := []struct {
+		name         string
+		dryRun       bool
+		annotations  map[string]string
+		wantReleased []workloadStatus
+		wantReplicas int32
+		// wantAnnotations are the annotations of the workload after the release
+		wantAnnotations map[string]string
+		wantEvent       string
+	}{
+		{
+			name:            "baseline restored",
+			annotations:     map[string]string{baselineAnnotation: "6"},
+			wantReleased:    []workloadStatus{{Namespace: "shop", Kind: "Deployment", Name: "web", Class: policy.Low, Replicas: 6, Baseline: int32Ptr(6), Released: true}},
+			wantReplicas:    6,
+			wantAnnotations: map[string]string{releasedAnnotation: "true"},
+			wantEvent:       "Normal Released Released by the release API, baseline of 6 replicas restored",
+		},
+		{
+			name:            "never changed",
+			wantReleased:    []workloadStatus{{Namespace: "shop", Kind: "Deployment", Name: "web", Class: policy.Low, Replicas: 2, Released: true}},
+			wantReplicas:    2,
+			wantAnnotations: map[string]string{releasedAnnotation: "true"},
+			wantEvent:       "Normal Released Released by the release API",
+		},
+		{
+			name:         "already released",
+			annotations:  map[string]string{releasedAnnotation: "true"},
+			wantReleased: []workloadStatus{},
+			wantReplicas: 2,
+			// nothing is patched
+			wantAnnotations: map[string]string{releasedAnnotation: "true"},
+		},
+		{
+			name:            "dry-run",
+			dryRun:          true,
+			annotations:     map[string]string{baselineAnnotation: "6"},
+			wantReleased:    []workloadStatus{{Namespace: "shop", Kind: "Deployment", Name: "web", Class: policy.Low, Replicas: 6, Baseline: int32Ptr(6), Released: true}},
+			wantReplicas:    2,
+			wantAnnotations: map[string]string{baselineAnnotation: "6"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dryRun = test.dryRun
+			scales := &testScales{replicas: map[string]int32{"shop/web": 2}}
+			c, recorder := newTestController(t, scales, workload(deployments, "web", policy.Low, 2, test.annotations))
+
+			released, err := c.Release("shop", "deployment", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(released, test.wantReleased) {
+				t.Errorf("got released %+v, want %+v", released, test.wantReleased)
+			}
+			if got := scales.replicas["shop/web"]; got != test.wantReplicas {
+				t.Errorf("got %d replicas, want %d", got, test.wantReplicas)
+			}
+			annotations := annotationsOf(t, c)
+			for _, key := range []string{baselineAnnotation, releasedAnnotation} {
+				want, wantOK := test.wantAnnotations[key]
+				if value, ok := annotations[key]; ok != wantOK || value != want {
+					t.Errorf("got annotations %v, want %s %q", annotations, key, want)
+				}
+			}
+			recorded := events(recorder)
+			if (test.wantEvent == "" && len(recorded) != 0) || (test.wantEvent != "" && !hasEvent(recorded, test.wantEvent)) {
+				t.Errorf("got events %q, want %q", recorded, test.wantEvent)
+			}
+		})
+	}
+
+	// engaging reads the workloads from the cache, which shows them released
+	for _, test := range []struct {
+		name         string
+		dryRun       bool
+		annotations  map[string]string
+		wantEngaged  int
+		wantReleased bool
+	}{
+		{name: "released", annotations: map[string]string{releasedAnnotation: "true"}, wantEngaged: 1},
+		{name: "dry-run", dryRun: true, annotations: map[string]string{releasedAnnotation: "true"}, wantEngaged: 1, wantReleased: true},
+		{name: "managed"},
+	} {
+		t.Run("engage "+test.name, func(t *testing.T) {
+			dryRun = test.dryRun
+			c, _ := newTestController(t, nil, workload(deployments, "web", policy.Low, 2, test.annotations))
+
+			engaged, err := c.Engage("", "", "web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(engaged) != test.wantEngaged {
+				t.Errorf("got engaged %+v, want %d", engaged, test.wantEngaged)
+			}
+			if _, ok := annotationsOf(t, c)[releasedAnnotation]; ok != test.wantReleased {
+				t.Errorf("got released %t, want %t", ok, test.wantReleased)
+			}
+		})
+	}
+}
+
+func TestReleaseFilters(t *testing.T) {
+	c, _ := newTestController(t, nil,
+		workload(deployments, "web", policy.Low, 2, nil),
+		workload(statefulSets, "db", policy.High, 1, nil),
+		// workloads without an importance class aren't managed
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"namespace": "shop", "name": "cache"},
+		}},
+	)
+	tests := []struct {
+		namespace, kind, name string
+		want                  []string
+	}{
+		{want: []string{"db", "web"}},
+		{kind: "statefulset", want: []string{"db"}},
+		{name: "web", want: []string{"web"}},
+		{namespace: "blog"},
+	}
+	for _, test := range tests {
+		workloads, err := c.managedWorkloads(test.namespace, test.kind, test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, w := range workloads {
+			names = append(names, w.GetName())
+		}
+		if !reflect.DeepEqual(names, test.want) {
+			t.Errorf("%s/%s/%s: got %v, want %v", test.namespace, test.kind, test.name, names, test.want)
+		}
+	}
+}
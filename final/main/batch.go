@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// suspendedAnnotation marks the CronJobs and Jobs suspended by the controller, so that those
+	// suspended by someone else are never resumed.
+	suspendedAnnotation = "kube-flux.io/suspended"
+	// pausedParallelismAnnotation keeps the parallelism of a Job paused by the controller on a
+	// cluster without Job suspension.
+	pausedParallelismAnnotation = "kube-flux.io/paused-parallelism"
+)
+
+// Reasons of the Events recorded on suspended CronJobs and paused Jobs.
+const (
+	reasonSuspended     = "Suspended"
+	reasonResumed       = "Resumed"
+	reasonSuspendFailed = "SuspendFailed"
+)
+
+// UseSuspension suspends the CronJobs, and with suspension.Jobs pauses the Jobs, of the
+// suspended importance classes. It must be called before the factory is started.
+func (c *Controller) UseSuspension(suspension scaling.Suspension, factory informers.SharedInformerFactory) {
+	c.suspension = suspension
+	enqueue := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		var class policy.Class
+		var ok bool
+		switch obj := obj.(type) {
+		case *batchv1.CronJob:
+			class, ok = importance(obj, obj.Spec.JobTemplate.Spec.Template.GetAnnotations())
+		case *batchv1.Job:
+			class, ok = importance(obj, obj.Spec.Template.GetAnnotations())
+		}
+		if ok {
+			c.queue.Add(class)
+		}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, newObj interface{}) { enqueue(newObj) },
+	}
+
+	cronJobInformer := factory.Batch().V1().CronJobs()
+	c.cronJobLister = cronJobInformer.Lister()
+	c.informersSynced = append(c.informersSynced, cronJobInformer.Informer().HasSynced)
+	cronJobInformer.Informer().AddEventHandler(handler)
+	if suspension.Jobs {
+		jobInformer := factory.Batch().V1().Jobs()
+		c.jobLister = jobInformer.Lister()
+		c.informersSynced = append(c.informersSynced, jobInformer.Informer().HasSynced)
+		jobInformer.Informer().AddEventHandler(handler)
+	}
+}
+
+// reconcileBatch suspends the CronJobs and pauses the Jobs of an importance class in every managed
+// namespace whose status suspends it, and resumes those the controller suspended otherwise.
+func (c *Controller) reconcileBatch(class policy.Class) error {
+	if c.cronJobLister == nil {
+		return nil
+	}
+	namespaces, err := c.managedNamespaces()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, namespace := range namespaces {
+		if err := c.reconcileNamespaceBatch(namespace, class); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// reconcileNamespaceBatch suspends or resumes the batch workloads of an importance class in a namespace.
+func (c *Controller) reconcileNamespaceBatch(namespace string, class policy.Class) error {
+	status := c.policyOf(namespace).Status
+	suspend := c.suspension.Suspends(status, class)
+
+	var errs []error
+	cronJobs, err := c.cronJobLister.CronJobs(namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, cj := range cronJobs {
+		if cjClass, ok := importance(cj, cj.Spec.JobTemplate.Spec.Template.GetAnnotations()); !ok || cjClass != class {
+			continue
+		}
+		if err := c.suspendCronJob(cj, class, suspend, status); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.jobLister != nil {
+		jobs, err := c.jobLister.Jobs(namespace).List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			if jobClass, ok := importance(job, job.Spec.Template.GetAnnotations()); !ok || jobClass != class {
+				continue
+			}
+			if err := c.pauseJob(job, class, suspend, status); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// suspendCronJob sets spec.suspend of a CronJob. Only CronJobs carrying suspendedAnnotation are resumed.
+func (c *Controller) suspendCronJob(cj *batchv1.CronJob, class policy.Class, suspend bool, status policy.Status) error {
+	suspended := cj.Spec.Suspend != nil && *cj.Spec.Suspend
+	_, ours := cj.GetAnnotations()[suspendedAnnotation]
+	var annotation *string
+	switch {
+	case suspend && !suspended:
+		value := string(status)
+		annotation = &value
+	case !suspend && ours:
+		// annotation stays nil, which removes it
+	default:
+		return nil
+	}
+
+	action := "suspend"
+	if !suspend {
+		action = "resume"
+	}
+	if dryRun {
+		fmt.Printf("Importance Factor %s) \t%s: [dry-run] %s CronJob\n", class, cj.GetName(), action)
+		return nil
+	}
+	patch, err := mergePatch(cj.GetResourceVersion(), map[string]interface{}{suspendedAnnotation: annotation}, map[string]interface{}{"suspend": suspend})
+	if err != nil {
+		return err
+	}
+	_, err = c.clientSet.BatchV1().CronJobs(cj.GetNamespace()).Patch(context.TODO(), cj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	c.recordSuspension(cj, "CronJob", class, action, status, err)
+	return err
+}
+
+// pauseJob suspends a running Job through spec.suspend, marking it with suspendedAnnotation, and
+// resumes it when the class is no longer suspended. Clusters without Job suspension drop
+// spec.suspend, the parallelism of the Job is then set to 0 instead and the previous parallelism
+// kept in pausedParallelismAnnotation. Jobs paused by someone else carry neither annotation and
+// are never resumed.
+func (c *Controller) pauseJob(job *batchv1.Job, class policy.Class, suspend bool, status policy.Status) error {
+	_, suspended := job.GetAnnotations()[suspendedAnnotation]
+	saved, paused := job.GetAnnotations()[pausedParallelismAnnotation]
+	var annotations, spec map[string]interface{}
+	switch {
+	case suspend && !suspended && !paused && !jobFinished(job):
+		if (job.Spec.Suspend != nil && *job.Spec.Suspend) || (job.Spec.Parallelism != nil && *job.Spec.Parallelism == 0) {
+			return nil
+		}
+		annotations = map[string]interface{}{suspendedAnnotation: string(status)}
+		spec = map[string]interface{}{"suspend": true}
+	case !suspend && suspended:
+		annotations = map[string]interface{}{suspendedAnnotation: nil}
+		spec = map[string]interface{}{"suspend": false}
+	case !suspend && paused:
+		restored, err := strconv.Atoi(saved)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation of Job %s/%s: %v", pausedParallelismAnnotation, job.GetNamespace(), job.GetName(), err)
+		}
+		annotations = map[string]interface{}{pausedParallelismAnnotation: nil}
+		spec = map[string]interface{}{"parallelism": restored}
+	default:
+		return nil
+	}
+
+	action := "pause"
+	if !suspend {
+		action = "resume"
+	}
+	if dryRun {
+		fmt.Printf("Importance Factor %s) \t%s: [dry-run] %s Job\n", class, job.GetName(), action)
+		return nil
+	}
+	patched, err := c.patchJob(job.GetNamespace(), job.GetName(), job.GetResourceVersion(), annotations, spec)
+	if err == nil && suspend && patched.Spec.Suspend == nil {
+		// the cluster doesn't support Job suspension, pause the Job through its parallelism
+		previous := "1"
+		if job.Spec.Parallelism != nil {
+			previous = strconv.Itoa(int(*job.Spec.Parallelism))
+		}
+		_, err = c.patchJob(job.GetNamespace(), job.GetName(), patched.GetResourceVersion(),
+			map[string]interface{}{suspendedAnnotation: nil, pausedParallelismAnnotation: previous},
+			map[string]interface{}{"parallelism": 0})
+	}
+	c.recordSuspension(job, "Job", class, action, status, err)
+	return err
+}
+
+// patchJob merge-patches the annotations and the spec of a Job.
+func (c *Controller) patchJob(namespace, name, resourceVersion string, annotations map[string]interface{}, spec map[string]interface{}) (*batchv1.Job, error) {
+	patch, err := mergePatch(resourceVersion, annotations, spec)
+	if err != nil {
+		return nil, err
+	}
+	return c.clientSet.BatchV1().Jobs(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+}
+
+// recordSuspension logs, counts and records as an Event the outcome of a suspend, pause or resume.
+func (c *Controller) recordSuspension(obj runtime.Object, kind string, class policy.Class, action string, status policy.Status, err error) {
+	meta, _ := obj.(metav1.Object)
+	suspensions.WithLabelValues(meta.GetNamespace(), kind, action, scaleResult(err)).Inc()
+	if err != nil {
+		log.Println("func", "recordSuspension", "Failed to", action, kind, meta.GetNamespace()+"/"+meta.GetName(), "err:", err)
+		if !apierrors.IsNotFound(err) {
+			c.recorder.Eventf(obj, corev1.EventTypeWarning, reasonSuspendFailed, "Failed to %s under status %s: %v", action, status, err)
+		}
+		return
+	}
+	fmt.Printf("Importance Factor %s) \t%s: %s %s under status %s\n", class, meta.GetName(), action, kind, status)
+	reason := reasonSuspended
+	if action == "resume" {
+		reason = reasonResumed
+	}
+	c.recorder.Eventf(obj, corev1.EventTypeNormal, reason, "%s %s under status %s", action, kind, status)
+}
+
+// mergePatch builds a merge patch setting annotations, a nil value removes one, and spec fields.
+// A non-empty resource version makes the patch fail on a concurrent change, e.g. someone
+// suspending the object by hand, which is then re-read from the cache before being retried.
+func mergePatch(resourceVersion string, annotations map[string]interface{}, spec map[string]interface{}) ([]byte, error) {
+	metadata := map[string]interface{}{"annotations": annotations}
+	if resourceVersion != "" {
+		metadata["resourceVersion"] = resourceVersion
+	}
+	patch := map[string]interface{}{"metadata": metadata}
+	if spec != nil {
+		patch["spec"] = spec
+	}
+	return json.Marshal(patch)
+}
+
+// jobFinished reports whether a Job completed or failed.
+func jobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
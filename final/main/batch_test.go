@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/policy"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// withoutJobSuspension makes the fake clientset drop spec.suspend of the Jobs it patches, like a
+// cluster without Job suspension.
+func withoutJobSuspension(clientSet *fake.Clientset) {
+	patch := k8stesting.ObjectReaction(clientSet.Tracker())
+	clientSet.PrependReactor("patch", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		handled, obj, err := patch(action)
+		if err != nil {
+			return handled, obj, err
+		}
+		job := obj.(*batchv1.Job)
+		job.Spec.Suspend = nil
+		return true, job, clientSet.Tracker().Update(action.GetResource(), job, job.GetNamespace())
+	})
+}
+
+func TestSuspendCronJob(t *testing.T) {
+	tests := []struct {
+		name          string
+		suspended     bool
+		annotations   map[string]string
+		suspend       bool
+		wantSuspended bool
+		wantOurs      bool
+		wantEvent     string
+	}{
+		{name: "suspend", suspend: true, wantSuspended: true, wantOurs: true, wantEvent: "Normal Suspended"},
+		{name: "resume", suspended: true, annotations: map[string]string{suspendedAnnotation: "Black"}, wantEvent: "Normal Resumed"},
+		{name: "suspended by someone else", suspended: true, wantSuspended: true},
+		{name: "already suspended", suspended: true, suspend: true, wantSuspended: true},
+		{name: "running", wantSuspended: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cj := &batchv1.CronJob{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "report", Annotations: test.annotations},
+				Spec:       batchv1.CronJobSpec{Suspend: boolPtr(test.suspended)},
+			}
+			c, recorder := newTestController(t, nil)
+			clientSet := fake.NewSimpleClientset(cj)
+			c.clientSet = clientSet
+
+			if err := c.suspendCronJob(cj, policy.Low, test.suspend, policy.Black); err != nil {
+				t.Fatal(err)
+			}
+			got, err := clientSet.BatchV1().CronJobs("shop").Get(context.TODO(), "report", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if suspended := got.Spec.Suspend != nil && *got.Spec.Suspend; suspended != test.wantSuspended {
+				t.Errorf("got suspended %t, want %t", suspended, test.wantSuspended)
+			}
+			if _, ours := got.GetAnnotations()[suspendedAnnotation]; ours != test.wantOurs {
+				t.Errorf("got annotations %v, want %s %t", got.GetAnnotations(), suspendedAnnotation, test.wantOurs)
+			}
+			recorded := events(recorder)
+			if (test.wantEvent == "" && len(recorded) != 0) || (test.wantEvent != "" && !hasEvent(recorded, test.wantEvent)) {
+				t.Errorf("got events %q, want %q", recorded, test.wantEvent)
+			}
+		})
+	}
+}
+
+func TestPauseJob(t *testing.T) {
+	finished := batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: "True"}}}
+	tests := []struct {
+		name        string
+		suspended   bool
+		parallelism *int32
+		annotations map[string]string
+		status      batchv1.JobStatus
+		// noSuspension drops spec.suspend, like a cluster without Job suspension
+		noSuspension    bool
+		suspend         bool
+		wantSuspended   bool
+		wantParallelism *int32
+		wantAnnotations map[string]string
+	}{
+		{
+			name: "pause", parallelism: int32Ptr(3), suspend: true,
+			wantSuspended: true, wantParallelism: int32Ptr(3), wantAnnotations: map[string]string{suspendedAnnotation: "Black"},
+		},
+		{
+			name: "resume", suspended: true, parallelism: int32Ptr(3), annotations: map[string]string{suspendedAnnotation: "Black"},
+			wantParallelism: int32Ptr(3), wantAnnotations: map[string]string{},
+		},
+		{
+			name: "pause through the parallelism", parallelism: int32Ptr(3), noSuspension: true, suspend: true,
+			wantParallelism: int32Ptr(0), wantAnnotations: map[string]string{pausedParallelismAnnotation: "3"},
+		},
+		{
+			name: "pause through the default parallelism", noSuspension: true, suspend: true,
+			wantParallelism: int32Ptr(0), wantAnnotations: map[string]string{pausedParallelismAnnotation: "1"},
+		},
+		{
+			name: "resume the parallelism", parallelism: int32Ptr(0), annotations: map[string]string{pausedParallelismAnnotation: "3"}, noSuspension: true,
+			wantParallelism: int32Ptr(3), wantAnnotations: map[string]string{},
+		},
+		{
+			name: "suspended by someone else", suspended: true, parallelism: int32Ptr(3),
+			wantSuspended: true, wantParallelism: int32Ptr(3),
+		},
+		{
+			name: "paused by someone else", parallelism: int32Ptr(0), suspend: true,
+			wantParallelism: int32Ptr(0),
+		},
+		{
+			name: "finished", parallelism: int32Ptr(3), status: finished, suspend: true,
+			wantParallelism: int32Ptr(3),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "backfill", Annotations: test.annotations},
+				Spec:       batchv1.JobSpec{Parallelism: test.parallelism},
+				Status:     test.status,
+			}
+			if test.suspended {
+				job.Spec.Suspend = boolPtr(true)
+			}
+			c, _ := newTestController(t, nil)
+			clientSet := fake.NewSimpleClientset(job)
+			if test.noSuspension {
+				withoutJobSuspension(clientSet)
+			}
+			c.clientSet = clientSet
+
+			if err := c.pauseJob(job, policy.Low, test.suspend, policy.Black); err != nil {
+				t.Fatal(err)
+			}
+			got, err := clientSet.BatchV1().Jobs("shop").Get(context.TODO(), "backfill", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if suspended := got.Spec.Suspend != nil && *got.Spec.Suspend; suspended != test.wantSuspended {
+				t.Errorf("got suspended %t, want %t", suspended, test.wantSuspended)
+			}
+			if (got.Spec.Parallelism == nil) != (test.wantParallelism == nil) || (got.Spec.Parallelism != nil && *got.Spec.Parallelism != *test.wantParallelism) {
+				t.Errorf("got parallelism %v, want %v", got.Spec.Parallelism, test.wantParallelism)
+			}
+			for _, key := range []string{suspendedAnnotation, pausedParallelismAnnotation} {
+				want, wantOK := test.wantAnnotations[key]
+				if test.wantAnnotations == nil {
+					want, wantOK = test.annotations[key]
+				}
+				if value, ok := got.GetAnnotations()[key]; ok != wantOK || value != want {
+					t.Errorf("got annotations %v, want %s %q", got.GetAnnotations(), key, want)
+				}
+			}
+		})
+	}
+}
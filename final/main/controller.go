@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/kube-flux/kube-flux/client/clientset/versioned"
+	listers "github.com/kube-flux/kube-flux/client/listers/kubeflux/v1alpha1"
+	"github.com/kube-flux/kube-flux/metrics"
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	autoscalinglisters "k8s.io/client-go/listers/autoscaling/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	policylisters "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/client-go/scale"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Controller reconciles the replicas of every importance class against the current policy.
+// Scalable workloads and Pods are watched through shared informers; the keys of the work queue
+// are importance classes, so a burst of events for one class results in a single reconciliation.
+type Controller struct {
+	clientSet kubernetes.Interface
+	// namespace holds the EnergyPolicy, and is the only namespace managed unless namespaceLister
+	// is set.
+	namespace string
+	// namespaceLister and namespaceSelector select the managed namespaces across the cluster.
+	namespaceLister   corelisters.NamespaceLister
+	namespaceSelector labels.Selector
+
+	// resources are the scalable resources watched, e.g. Deployments and StatefulSets, in the
+	// order of -scale-kinds; workloadListers list their objects.
+	resources       []schema.GroupVersionResource
+	workloadListers map[schema.GroupVersionResource]cache.GenericLister
+	// scales changes the replicas of any workload through its scale subresource, dynamicClient
+	// its annotations.
+	scales          scale.ScalesGetter
+	dynamicClient   dynamic.Interface
+	podLister       corelisters.PodLister
+	informersSynced []cache.InformerSynced
+	metrics         metrics.MetricsSource
+
+	// suspension selects the classes whose CronJobs and Jobs are suspended, cronJobLister and
+	// jobLister are set when any is.
+	suspension    scaling.Suspension
+	cronJobLister batchlisters.CronJobLister
+	jobLister     batchlisters.JobLister
+	// pdbLister is set when scale-downs respect PodDisruptionBudgets.
+	pdbLister policylisters.PodDisruptionBudgetLister
+	// hpaLister is set when the HorizontalPodAutoscalers of workloads are bounded instead of
+	// scaling the workloads.
+	hpaLister autoscalinglisters.HorizontalPodAutoscalerLister
+
+	// fluxClient, energyPolicyLister and energyPolicyName are set when an EnergyPolicy is watched.
+	fluxClient         versioned.Interface
+	energyPolicyLister listers.EnergyPolicyLister
+	energyPolicyName   string
+	// appliedResourceVersion is the resource version of the EnergyPolicy last copied into the
+	// policy, guarded by policyLock.
+	appliedResourceVersion string
+	// source is set when the policy is read from Zeus or a file.
+	source PolicySource
+
+	queue         workqueue.RateLimitingInterface
+	metricsResync time.Duration
+	// releaseStatus is the unconstrained energy status, under which the baseline replicas of
+	// every workload are restored instead of applying factors; empty to always apply factors.
+	releaseStatus policy.Status
+	// stabilizer limits how fast the replicas of every workload change.
+	stabilizer *scaling.Stabilizer
+	// recorder records the replica changes and their failures as Events on the workloads.
+	recorder record.EventRecorder
+
+	// mu guards usage, factors, targets, pinnedFactor, fileRules, policyRules, overrides and releasing.
+	mu sync.Mutex
+	// usage is the average usage of the pods of every importance class of every managed namespace
+	// at the last evaluation.
+	usage map[string]map[policy.Class]metrics.Usage
+	// factors are the replica factors derived from the usage of every managed namespace, nil
+	// while pinnedFactor is set. The factors of the controller's namespace are also published
+	// as the factors of the policy when it is the only one managed.
+	factors map[string]policy.Factors
+	// targets are the scaling directions of the last usage evaluation of every managed
+	// namespace, nil to apply the replica factors as is after a policy change.
+	targets map[string]*scaling.Targets
+	// pinnedFactor are the replica factors of the EnergyPolicy, which take precedence over
+	// the factors derived from usage.
+	pinnedFactor policy.Factors
+	// fileRules are the scaling rules of -scaling-rules, policyRules those of the EnergyPolicy.
+	fileRules   *scaling.RuleSet
+	policyRules *scaling.RuleSet
+	// overrides are the parsed EnergyPolicies overriding the policy of the managed namespaces.
+	overrides map[string]*override
+	// releasing are the keys of the workloads being released through the release API.
+	releasing map[string]bool
+}
+
+// NewController creates a Controller watching the workloads of resources and the Pods of a namespace,
+// see UseNamespaces to manage several.
+// Workloads are read through dynamicFactory and scaled through scales, so that any resource with
+// a scale subresource is handled like a Deployment. The usage of pods is only read from source
+// once every metricsResync.
+func NewController(clientSet kubernetes.Interface, factory informers.SharedInformerFactory, dynamicClient dynamic.Interface, dynamicFactory dynamicinformer.DynamicSharedInformerFactory, scales scale.ScalesGetter, resources []schema.GroupVersionResource, source metrics.MetricsSource, namespace string, metricsResync time.Duration) *Controller {
+	podInformer := factory.Core().V1().Pods()
+
+	c := &Controller{
+		clientSet:       clientSet,
+		namespace:       namespace,
+		resources:       resources,
+		workloadListers: make(map[schema.GroupVersionResource]cache.GenericLister, len(resources)),
+		scales:          scales,
+		dynamicClient:   dynamicClient,
+		podLister:       podInformer.Lister(),
+		metrics:         source,
+		informersSynced: []cache.InformerSynced{podInformer.Informer().HasSynced},
+		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "importance-classes"),
+		metricsResync:   metricsResync,
+		stabilizer:      scaling.NewStabilizer(scaling.Behavior{}),
+		recorder:        newEventRecorder(clientSet),
+	}
+
+	for _, resource := range resources {
+		resource := resource
+		informer := dynamicFactory.ForResource(resource)
+		c.workloadListers[resource] = informer.Lister()
+		c.informersSynced = append(c.informersSynced, informer.Informer().HasSynced)
+		informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) { c.enqueueWorkload(resource, obj) },
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				// the importance class of a workload may have changed
+				c.enqueueWorkload(resource, oldObj)
+				c.enqueueWorkload(resource, newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+					namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+					c.stabilizer.Forget(scaling.WorkloadKey(resource, namespace, name))
+				}
+				c.enqueueWorkload(resource, obj)
+			},
+		})
+	}
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueuePod,
+		DeleteFunc: c.enqueuePod,
+	})
+	return c
+}
+
+// Run waits for the informer caches, evaluates the metrics once and then starts the workers.
+// It blocks until stopCh is closed.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	if c.energyPolicyName != "" && !dryRun {
+		if err := c.ensureEnergyPolicy(); err != nil {
+			return fmt.Errorf("failed to ensure EnergyPolicy %s: %v", c.energyPolicyName, err)
+		}
+	}
+
+	log.Println("func", "Run", "Waiting for informer caches to sync")
+	if !cache.WaitForCacheSync(stopCh, c.informersSynced...) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	if c.source != nil {
+		go c.source.Run(stopCh, c.ApplyPolicy)
+	}
+
+	// evaluate usage before the first reconciliation so that the workers start with current factors
+	c.resyncMetrics()
+	go wait.Until(c.resyncMetrics, c.metricsResync, stopCh)
+
+	log.Println("func", "Run", "Starting workers", workers)
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	log.Println("func", "Run", "Shutting down workers")
+	return nil
+}
+
+// UseReleaseStatus restores the baseline replicas of every workload under status, empty to
+// apply the replica factors of every status. It must be called before Run.
+func (c *Controller) UseReleaseStatus(status policy.Status) {
+	c.releaseStatus = status
+}
+
+// UseScalingBehavior limits how fast the replicas of every workload change.
+// It must be called before Run.
+func (c *Controller) UseScalingBehavior(behavior scaling.Behavior) {
+	c.stabilizer = scaling.NewStabilizer(behavior)
+}
+
+// PolicyChanged makes the workers apply the replica factors of the new policy status to every class.
+func (c *Controller) PolicyChanged() {
+	c.mu.Lock()
+	c.targets = nil
+	c.mu.Unlock()
+	c.enqueueAll()
+}
+
+// enqueueAll adds every importance class to the work queue.
+func (c *Controller) enqueueAll() {
+	for _, class := range policy.Classes {
+		c.queue.Add(class)
+	}
+}
+
+// enqueueWorkload adds the importance class of a workload of resource to the work queue.
+func (c *Controller) enqueueWorkload(resource schema.GroupVersionResource, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	runtimeObj, ok := obj.(runtime.Object)
+	if !ok {
+		return
+	}
+	w, ok := scaling.NewWorkload(resource, runtimeObj)
+	if !ok || !w.Managed() || !c.manages(w.GetNamespace()) {
+		return
+	}
+	if class, ok := workloadImportance(w); ok {
+		c.queue.Add(class)
+	}
+}
+
+// enqueuePod adds the importance class of the workloads selecting a pod to the work queue.
+func (c *Controller) enqueuePod(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !c.manages(pod.GetNamespace()) {
+		return
+	}
+	workloads, err := c.listWorkloads(pod.GetNamespace())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, w := range workloads {
+		selector, err := w.Selector()
+		if err != nil || !selector.Matches(labels.Set(pod.GetLabels())) {
+			continue
+		}
+		c.enqueueWorkload(w.Resource, w.Unstructured)
+	}
+}
+
+// listWorkloads lists the workloads of every watched resource in a namespace from the informer caches.
+func (c *Controller) listWorkloads(namespace string) ([]*scaling.Workload, error) {
+	var workloads []*scaling.Workload
+	for _, resource := range c.resources {
+		objs, err := c.workloadListers[resource].ByNamespace(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			if w, ok := scaling.NewWorkload(resource, obj); ok {
+				workloads = append(workloads, w)
+			}
+		}
+	}
+	return workloads, nil
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+// processNextWorkItem reconciles one importance class, requeueing it with back-off on failure.
+func (c *Controller) processNextWorkItem() bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	var err error
+	switch key := key.(type) {
+	case policy.Class:
+		err = c.reconcile(key)
+	case overrideKey:
+		err = c.syncOverride(string(key))
+	default:
+		err = c.syncEnergyPolicy()
+	}
+	if err != nil {
+		reconcileErrors.WithLabelValues(fmt.Sprint(key)).Inc()
+		utilruntime.HandleError(fmt.Errorf("failed to reconcile %v: %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+// reconcile brings the replicas of every workload in an importance class to the target of the current policy,
+// then suspends or resumes its batch workloads. With -dry-run the changes are only printed.
+func (c *Controller) reconcile(class policy.Class) error {
+	changes, err := c.planClass(class)
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := c.managedNamespaces()
+	if err != nil {
+		return err
+	}
+	unmet := make(map[string]int, len(namespaces))
+	for _, namespace := range namespaces {
+		unmet[namespace] = 0
+	}
+
+	var errs []error
+	for _, change := range changes {
+		if change.UnmetTarget != nil {
+			unmet[change.Namespace]++
+			if !dryRun {
+				c.recorder.Eventf(change.workload, corev1.EventTypeWarning, reasonTargetUnmet, "Energy target of %d replicas not met: %s", *change.UnmetTarget, change.Reason)
+			}
+		}
+		if dryRun {
+			if change.Desired != change.Current {
+				fmt.Printf("Importance Factor %s) \t%s: [dry-run] %d -> %d replica-set: %s\n", class, change.Name, change.Current, change.Desired, change.Reason)
+			}
+			continue
+		}
+		if change.hpa != nil {
+			err := c.changeAutoscaler(change)
+			if err != nil && !errors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			if err == nil && change.Desired != change.Current {
+				c.stabilizer.Changed(change.workload.Key())
+			}
+			continue
+		}
+		if change.Desired != change.Current && !change.restore {
+			if err := c.recordBaseline(change.workload); err != nil {
+				if !errors.IsNotFound(err) {
+					errs = append(errs, fmt.Errorf("failed to record the baseline of %s: %v", change.workload.Key(), err))
+				}
+				continue
+			}
+		}
+		err := c.changeReplica(change)
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+		if err == nil && change.Desired != change.Current {
+			c.stabilizer.Changed(change.workload.Key())
+		}
+		if err == nil && change.restore && change.Baseline != nil && change.Desired == *change.Baseline {
+			// the workload is back to its baseline, the next constrained status records a new one
+			if err := c.dropBaseline(change.workload); err != nil && !errors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for namespace, count := range unmet {
+		unmetTargets.WithLabelValues(namespace, string(class)).Set(float64(count))
+	}
+	if err := c.reconcileBatch(class); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// resyncMetrics refreshes the average usage of every importance class from the metrics API,
+// re-evaluates the replica factors and queues every class for reconciliation.
+func (c *Controller) resyncMetrics() {
+	if err := c.evaluate(); err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	fmt.Printf("\n--------------------- [Change of Relica-set] ---------------------\n")
+	c.enqueueAll()
+}
+
+// evaluate refreshes the average usage of every importance class of every managed namespace and
+// re-evaluates the replica factors of every namespace with the scaling rules, unless the
+// EnergyPolicy pins them.
+func (c *Controller) evaluate() error {
+	if err := c.refreshUsage(); err != nil {
+		usageRefreshErrors.Inc()
+		return fmt.Errorf("failed to refresh pod usage: %v", err)
+	}
+
+	c.mu.Lock()
+	// refreshUsage replaces the map, it is never changed in place
+	namespaceUsage := c.usage
+	c.mu.Unlock()
+	rules := make(map[string]*scaling.RuleSet, len(namespaceUsage))
+	for namespace := range namespaceUsage {
+		rules[namespace] = c.scalingRules(namespace)
+	}
+	policyLock.RLock()
+	policyFactor := currPolicy.Factor
+	policyLock.RUnlock()
+
+	c.mu.Lock()
+	factors := make(map[string]policy.Factors, len(namespaceUsage))
+	targets := make(map[string]*scaling.Targets, len(namespaceUsage))
+	for namespace, usage := range namespaceUsage {
+		// classes without a matching band keep the factor of the last evaluation
+		current, ok := c.factors[namespace]
+		if !ok {
+			current = policyFactor
+		}
+		factors[namespace], targets[namespace] = autoAdjustReplica(rules[namespace], current, usage)
+	}
+	pinned := c.pinnedFactor != nil
+	if pinned {
+		factors = nil
+	}
+	c.factors, c.targets = factors, targets
+	c.mu.Unlock()
+
+	factor, ok := factors[c.namespace]
+	if pinned || !ok || c.namespaceLister != nil {
+		return nil
+	}
+	policyLock.Lock()
+	if !reflect.DeepEqual(currPolicy.Factor, factor) {
+		currPolicy.Factor = factor
+		publishPolicy()
+	}
+	policyLock.Unlock()
+	return nil
+}
+
+// factorsOf returns the replica factors derived from the usage of a namespace, false when they
+// are pinned or the namespace wasn't evaluated yet.
+func (c *Controller) factorsOf(namespace string) (policy.Factors, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pinnedFactor != nil {
+		return nil, false
+	}
+	factors, ok := c.factors[namespace]
+	return factors, ok
+}
+
+// actionOf returns the action of the band that set the target of a class in a namespace at the
+// last evaluation, empty when none did.
+func (c *Controller) actionOf(namespace string, status policy.Status, class policy.Class) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.targets[namespace].Action(status, class)
+}
+
+// refreshUsage calculates the average cpu and memory usage of pods in the same importance class
+// of every managed namespace. Workloads and pods come from the informer caches, the usage of
+// the pods of every class is read from the metrics source in a single call per namespace.
+func (c *Controller) refreshUsage() error {
+	namespaces, err := c.managedNamespaces()
+	if err != nil {
+		return err
+	}
+	usage := make(map[string]map[policy.Class]metrics.Usage, len(namespaces))
+	for _, namespace := range namespaces {
+		if usage[namespace], err = c.namespaceUsage(namespace); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	c.usage = usage
+	c.mu.Unlock()
+	return nil
+}
+
+// namespaceUsage calculates the average cpu and memory usage of the pods of every importance
+// class in a namespace. Classes without pods reporting usage are left out.
+func (c *Controller) namespaceUsage(namespace string) (map[policy.Class]metrics.Usage, error) {
+	workloads, err := c.listWorkloads(namespace)
+	if err != nil {
+		return nil, err
+	}
+	classes := groupWorkloads(workloads)
+	pods := make(map[policy.Class][]metrics.Pods)
+	for _, class := range policy.Classes {
+		for _, w := range classes[class] {
+			selector, err := w.Selector()
+			if err != nil {
+				return nil, err
+			}
+			workloadPods, err := c.podLister.Pods(namespace).List(selector)
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, 0, len(workloadPods))
+			for _, pod := range workloadPods {
+				names = append(names, pod.GetName())
+			}
+			pods[class] = append(pods[class], metrics.Pods{Selector: selector, Names: names})
+		}
+	}
+	usage := make(map[policy.Class]metrics.Usage, len(policy.Classes))
+	if len(pods) == 0 {
+		return usage, nil
+	}
+	usages, err := c.metrics.PodUsage(context.TODO(), namespace, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, class := range policy.Classes {
+		var cpuSum, memorySum float64
+		numOfPods := 0
+		for _, podName := range podNames(pods[class]) {
+			podUsage, ok := usages[podName]
+			if !ok {
+				// e.g. just started, left out of the average until it reports usage
+				continue
+			}
+			if containerBreakdown {
+				fmt.Printf("Pod %s/%s) \tCPU %dn, Memory %d bytes\n", namespace, podName, podUsage.CPU, podUsage.Memory)
+				printContainerUsage(podUsage)
+			}
+			cpuSum += float64(podUsage.CPU)
+			memorySum += float64(podUsage.Memory)
+			numOfPods++
+		}
+		if numOfPods != 0 {
+			usage[class] = metrics.Usage{
+				CPU:    int64(cpuSum / float64(numOfPods)),
+				Memory: int64(memorySum / float64(numOfPods)),
+			}
+		}
+	}
+	return usage, nil
+}
+
+// podNames returns the names of the pods of every workload.
+func podNames(workloads []metrics.Pods) []string {
+	var names []string
+	for _, workload := range workloads {
+		names = append(names, workload.Names...)
+	}
+	return names
+}
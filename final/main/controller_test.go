@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	fakescale "k8s.io/client-go/scale/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var (
+	deployments  = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	statefulSets = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}
+)
+
+// workload returns a Deployment or StatefulSet of namespace shop in an importance class.
+func workload(resource schema.GroupVersionResource, name string, class policy.Class, replicas int64, annotations map[string]string) *unstructured.Unstructured {
+	kind := "Deployment"
+	if resource == statefulSets {
+		kind = "StatefulSet"
+	}
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"namespace":       "shop",
+			"name":            name,
+			"resourceVersion": "1",
+			"labels":          map[string]interface{}{importanceLabel: string(class)},
+		},
+		"spec": map[string]interface{}{"replicas": replicas},
+	}}
+	if annotations != nil {
+		u.SetAnnotations(annotations)
+	}
+	return u
+}
+
+// testScales serves the scale subresource of workloads from their replicas, conflicting is the
+// number of updates answered with a conflict before the next one succeeds.
+type testScales struct {
+	replicas    map[string]int32
+	conflicting int
+	err         error
+	updates     int
+}
+
+// scaleClient returns a fake scale client backed by s.
+func (s *testScales) scaleClient() *fakescale.FakeScaleClient {
+	client := &fakescale.FakeScaleClient{}
+	client.AddReactor("get", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		replicas, ok := s.replicas[get.GetNamespace()+"/"+get.GetName()]
+		if !ok {
+			return true, nil, apierrors.NewNotFound(get.GetResource().GroupResource(), get.GetName())
+		}
+		return true, &autoscalingv1.Scale{
+			ObjectMeta: metav1.ObjectMeta{Namespace: get.GetNamespace(), Name: get.GetName()},
+			Spec:       autoscalingv1.ScaleSpec{Replicas: replicas},
+		}, nil
+	})
+	client.AddReactor("update", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		update := action.(k8stesting.UpdateAction)
+		scale := update.GetObject().(*autoscalingv1.Scale)
+		if s.err != nil {
+			return true, nil, s.err
+		}
+		if s.conflicting > 0 {
+			s.conflicting--
+			return true, nil, apierrors.NewConflict(update.GetResource().GroupResource(), scale.GetName(), fmt.Errorf("the object has been modified"))
+		}
+		s.updates++
+		s.replicas[scale.GetNamespace()+"/"+scale.GetName()] = scale.Spec.Replicas
+		return true, scale, nil
+	})
+	return client
+}
+
+// newTestController returns a Controller managing namespace shop, whose workloads are listed
+// from objs and served by a fake dynamic client, scaled through scales and whose Events are
+// recorded by a fake recorder.
+func newTestController(t *testing.T, scales *testScales, objs ...*unstructured.Unstructured) (*Controller, *record.FakeRecorder) {
+	t.Helper()
+	indexers := map[schema.GroupVersionResource]cache.Indexer{
+		deployments:  cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+		statefulSets: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+	}
+	var dynamicObjs []runtime.Object
+	for _, obj := range objs {
+		resource := deployments
+		if obj.GetKind() == "StatefulSet" {
+			resource = statefulSets
+		}
+		if err := indexers[resource].Add(obj); err != nil {
+			t.Fatal(err)
+		}
+		dynamicObjs = append(dynamicObjs, obj.DeepCopy())
+	}
+	if scales == nil {
+		scales = &testScales{replicas: map[string]int32{}}
+	}
+	recorder := record.NewFakeRecorder(10)
+	scheme := runtime.NewScheme()
+	c := &Controller{
+		clientSet: fake.NewSimpleClientset(),
+		namespace: "shop",
+		resources: []schema.GroupVersionResource{deployments, statefulSets},
+		workloadListers: map[schema.GroupVersionResource]cache.GenericLister{
+			deployments:  cache.NewGenericLister(indexers[deployments], deployments.GroupResource()),
+			statefulSets: cache.NewGenericLister(indexers[statefulSets], statefulSets.GroupResource()),
+		},
+		scales: scales.scaleClient(),
+		dynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+			deployments:  "DeploymentList",
+			statefulSets: "StatefulSetList",
+		}, dynamicObjs...),
+		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		stabilizer: scaling.NewStabilizer(scaling.Behavior{}),
+		recorder:   recorder,
+	}
+	return c, recorder
+}
+
+// setPolicy replaces the current policy.
+func setPolicy(p *policy.Policy) {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	currPolicy = p
+}
+
+// events drains the Events recorded so far.
+func events(recorder *record.FakeRecorder) []string {
+	var recorded []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			recorded = append(recorded, event)
+		default:
+			return recorded
+		}
+	}
+}
+
+// hasEvent reports whether an Event starts with prefix, e.g. "Normal Scaled".
+func hasEvent(recorded []string, prefix string) bool {
+	for _, event := range recorded {
+		if strings.HasPrefix(event, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/kube-flux/kube-flux/apis/kubeflux/v1alpha1"
+	"github.com/kube-flux/kube-flux/client/clientset/versioned"
+	"github.com/kube-flux/kube-flux/client/informers/externalversions"
+	"github.com/kube-flux/kube-flux/policy"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// energyPolicyKey is the work queue key of the watched EnergyPolicy; it never collides with an importance class.
+const energyPolicyKey = "EnergyPolicy"
+
+// WatchEnergyPolicy makes the named EnergyPolicy of the controller's namespace the source of truth
+// for the energy status, the replica factors and the scaling rules. When several namespaces are
+// managed, the EnergyPolicies of the same name in the other namespaces override it there.
+func (c *Controller) WatchEnergyPolicy(fluxClient versioned.Interface, factory externalversions.SharedInformerFactory, name string) {
+	informer := factory.Kubeflux().V1alpha1().EnergyPolicies()
+	c.fluxClient = fluxClient
+	c.energyPolicyLister = informer.Lister()
+	c.energyPolicyName = name
+	c.informersSynced = append(c.informersSynced, informer.Informer().HasSynced)
+
+	enqueue := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		ep, ok := obj.(*v1alpha1.EnergyPolicy)
+		if !ok || ep.GetName() != name {
+			return
+		}
+		if ep.GetNamespace() != c.namespace {
+			c.queue.Add(overrideKey(ep.GetNamespace()))
+			return
+		}
+		c.queue.Add(energyPolicyKey)
+	}
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, newObj interface{}) { enqueue(newObj) },
+		DeleteFunc: enqueue,
+	})
+}
+
+// SetPolicy changes the energy status and, when the request carries any, the replica factors.
+// With an EnergyPolicy configured the change is written to its spec and picked up through the
+// informer, otherwise the in-memory policy is changed directly. A policy read from Zeus or a
+// file can't be changed, SetPolicy returns errReadOnlyPolicy, and with -dry-run neither can the
+// EnergyPolicy, SetPolicy returns errDryRun.
+// A non-empty ifMatch is an If-Match header the current revision must match, otherwise
+// policy.ErrPreconditionFailed is returned.
+func (c *Controller) SetPolicy(request *policy.Policy, ifMatch string) error {
+	if c.source != nil {
+		return errReadOnlyPolicy
+	}
+	if c.energyPolicyName == "" {
+		request = request.DeepCopy()
+		request.UpdatedAt = time.Now()
+		return c.applyPolicy(request, ifMatch)
+	}
+
+	if dryRun {
+		return errDryRun
+	}
+	policyLock.RLock()
+	matches := policy.IfMatch(ifMatch, currPolicy.Revision)
+	resourceVersion := c.appliedResourceVersion
+	policyLock.RUnlock()
+	if !matches {
+		return policy.ErrPreconditionFailed
+	}
+	patchObject := map[string]interface{}{}
+	spec := map[string]interface{}{"status": string(request.Status)}
+	if len(request.Factor) != 0 {
+		spec["factors"] = specFactors(request.Factor)
+	}
+	if request.Percentages != nil {
+		// null removes the percentages of a merge patch, {} would keep them
+		spec["percentages"] = nil
+		if len(request.Percentages) != 0 {
+			spec["percentages"] = specPercentages(request.Percentages)
+		}
+	}
+	patchObject["spec"] = spec
+	if ifMatch != "" {
+		// the API server rejects the patch if the EnergyPolicy changed since the matched revision
+		patchObject["metadata"] = map[string]interface{}{"resourceVersion": resourceVersion}
+	}
+	patch, err := json.Marshal(patchObject)
+	if err != nil {
+		return err
+	}
+	_, err = c.fluxClient.KubefluxV1alpha1().EnergyPolicies(c.namespace).Patch(context.TODO(), c.energyPolicyName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if ifMatch != "" && errors.IsConflict(err) {
+		return policy.ErrPreconditionFailed
+	}
+	return err
+}
+
+// ensureEnergyPolicy creates the watched EnergyPolicy from the in-memory policy if it doesn't exist yet.
+func (c *Controller) ensureEnergyPolicy() error {
+	_, err := c.fluxClient.KubefluxV1alpha1().EnergyPolicies(c.namespace).Get(context.TODO(), c.energyPolicyName, metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	policyLock.RLock()
+	ep := &v1alpha1.EnergyPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: c.energyPolicyName, Namespace: c.namespace},
+		Spec: v1alpha1.EnergyPolicySpec{
+			Status:      string(currPolicy.Status),
+			Factors:     specFactors(currPolicy.Factor),
+			Percentages: specPercentages(currPolicy.Percentages),
+		},
+	}
+	_, err = c.fluxClient.KubefluxV1alpha1().EnergyPolicies(c.namespace).Create(context.TODO(), ep, metav1.CreateOptions{})
+	policyLock.RUnlock()
+	if err == nil {
+		log.Println("func", "ensureEnergyPolicy", "Created EnergyPolicy", c.energyPolicyName)
+	}
+	return err
+}
+
+// syncEnergyPolicy copies the spec of the watched EnergyPolicy into the policy and records
+// the applied generation in its status.
+func (c *Controller) syncEnergyPolicy() error {
+	ep, err := c.energyPolicyLister.EnergyPolicies(c.namespace).Get(c.energyPolicyName)
+	if errors.IsNotFound(err) {
+		log.Println("func", "syncEnergyPolicy", "EnergyPolicy", c.energyPolicyName, "not found, keeping the current policy")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reason, err := c.applyEnergyPolicy(ep)
+	if err != nil {
+		return c.setEnergyPolicyCondition(ep, metav1.ConditionFalse, reason, err.Error())
+	}
+	status, _ := policy.ParseStatus(ep.Spec.Status)
+	message := fmt.Sprintf("Replica factors of status %s queued for every importance class", status)
+	return c.setEnergyPolicyCondition(ep, metav1.ConditionTrue, reason, message)
+}
+
+// applyEnergyPolicy copies the spec of an EnergyPolicy into the policy and the scaling rules.
+// It returns the reason of the Applied condition, along with why the spec is invalid.
+func (c *Controller) applyEnergyPolicy(ep *v1alpha1.EnergyPolicy) (string, error) {
+	status, factors, percentages, reason, err := parseEnergyPolicySpec(&ep.Spec)
+	if err != nil {
+		return reason, err
+	}
+	rules, err := energyPolicyRules(&ep.Spec)
+	if err != nil {
+		return "InvalidRules", err
+	}
+
+	policyLock.Lock()
+	statusChanged := currPolicy.Status != status
+	factorsChanged := len(factors) != 0 && !reflect.DeepEqual(currPolicy.Factor, factors)
+	// the spec holds every percentage, none removes them
+	percentagesChanged := !reflect.DeepEqual(currPolicy.Percentages, percentages)
+	currPolicy.Status = status
+	if factorsChanged {
+		currPolicy.Factor = factors
+	}
+	if percentagesChanged {
+		currPolicy.Percentages = percentages
+	}
+	if statusChanged || factorsChanged || percentagesChanged {
+		currPolicy.UpdatedAt = time.Now()
+		publishPolicy()
+	}
+	c.appliedResourceVersion = ep.GetResourceVersion()
+	policyLock.Unlock()
+
+	c.mu.Lock()
+	c.pinnedFactor = nil
+	if len(factors) != 0 {
+		c.pinnedFactor = factors
+	}
+	c.policyRules = rules
+	c.mu.Unlock()
+
+	if statusChanged || percentagesChanged || ep.Status.ObservedGeneration != ep.GetGeneration() {
+		c.PolicyChanged()
+	}
+	return "PolicyApplied", nil
+}
+
+// parseEnergyPolicySpec validates the status, the factors and the percentages of an EnergyPolicy
+// spec. It returns the reason of the Applied condition along with why the spec is invalid.
+func parseEnergyPolicySpec(spec *v1alpha1.EnergyPolicySpec) (policy.Status, policy.Factors, policy.Percentages, string, error) {
+	status, err := policy.ParseStatus(spec.Status)
+	if err != nil {
+		return "", nil, nil, "InvalidStatus", err
+	}
+	factors := policy.ConvertFactors(spec.Factors)
+	if err := factors.Validate(policyLimits); err != nil {
+		return "", nil, nil, "InvalidFactors", err
+	}
+	percentages := convertPercentages(spec.Percentages)
+	if err := percentages.Validate(policyLimits); err != nil {
+		return "", nil, nil, "InvalidPercentages", err
+	}
+	return status, factors, percentages, "", nil
+}
+
+// setEnergyPolicyCondition records the Applied condition and the observed generation in the status of an EnergyPolicy.
+// Nothing is written when the generation was already observed with the same outcome, so that the
+// status update doesn't trigger another sync, nor with -dry-run.
+func (c *Controller) setEnergyPolicyCondition(ep *v1alpha1.EnergyPolicy, conditionStatus metav1.ConditionStatus, reason string, message string) error {
+	condition := meta.FindStatusCondition(ep.Status.Conditions, v1alpha1.ConditionApplied)
+	if condition != nil && condition.Status == conditionStatus && condition.Reason == reason && ep.Status.ObservedGeneration == ep.GetGeneration() {
+		return nil
+	}
+	if dryRun {
+		log.Println("func", "setEnergyPolicyCondition", "[dry-run] EnergyPolicy", ep.GetNamespace()+"/"+ep.GetName(), conditionStatus, reason, message)
+		return nil
+	}
+
+	// the lister returns a shared object, update the status of a copy
+	ep = ep.DeepCopy()
+	now := metav1.Now()
+	ep.Status.ObservedGeneration = ep.GetGeneration()
+	if conditionStatus == metav1.ConditionTrue {
+		ep.Status.LastAppliedTime = &now
+	}
+	meta.SetStatusCondition(&ep.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionApplied,
+		Status:             conditionStatus,
+		ObservedGeneration: ep.GetGeneration(),
+		Reason:             reason,
+		Message:            message,
+	})
+	_, err := c.fluxClient.KubefluxV1alpha1().EnergyPolicies(ep.GetNamespace()).UpdateStatus(context.TODO(), ep, metav1.UpdateOptions{})
+	return err
+}
+
+// specFactors converts Factors into the factor map of an EnergyPolicy spec.
+func specFactors(factors policy.Factors) map[string]map[string]int32 {
+	spec := make(map[string]map[string]int32, len(factors))
+	for status, classes := range factors {
+		spec[string(status)] = make(map[string]int32, len(classes))
+		for class, replicas := range classes {
+			spec[string(status)][string(class)] = replicas
+		}
+	}
+	return spec
+}
+
+// convertPercentages converts the percentages of an EnergyPolicy spec into Percentages.
+// Unknown status and class names are kept as is and rejected by Validate.
+func convertPercentages(spec map[string]map[string]v1alpha1.ReplicaPercentage) policy.Percentages {
+	if len(spec) == 0 {
+		return nil
+	}
+	percentages := make(policy.Percentages, len(spec))
+	for statusName, classes := range spec {
+		status, err := policy.ParseStatus(statusName)
+		if err != nil {
+			status = policy.Status(statusName)
+		}
+		percentages[status] = make(map[policy.Class]policy.Percentage, len(classes))
+		for className, percentage := range classes {
+			class, err := policy.ParseClass(className)
+			if err != nil {
+				class = policy.Class(className)
+			}
+			percentages[status][class] = policy.Percentage{Percent: percentage.Percent, Min: percentage.Min, Max: percentage.Max}
+		}
+	}
+	return percentages
+}
+
+// specPercentages converts Percentages into the percentages of an EnergyPolicy spec.
+func specPercentages(percentages policy.Percentages) map[string]map[string]v1alpha1.ReplicaPercentage {
+	if percentages == nil {
+		return nil
+	}
+	spec := make(map[string]map[string]v1alpha1.ReplicaPercentage, len(percentages))
+	for status, classes := range percentages {
+		spec[string(status)] = make(map[string]v1alpha1.ReplicaPercentage, len(classes))
+		for class, percentage := range classes {
+			spec[string(status)][string(class)] = v1alpha1.ReplicaPercentage{Percent: percentage.Percent, Min: percentage.Min, Max: percentage.Max}
+		}
+	}
+	return spec
+}
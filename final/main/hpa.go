@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+
+	"github.com/kube-flux/kube-flux/scaling"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// originalMinReplicasAnnotation and originalMaxReplicasAnnotation keep the bounds a
+	// HorizontalPodAutoscaler had before the first energy-driven change.
+	originalMinReplicasAnnotation = "kube-flux.io/original-min-replicas"
+	originalMaxReplicasAnnotation = "kube-flux.io/original-max-replicas"
+)
+
+// autoscalerResource is the resource label of the scale operations on HorizontalPodAutoscalers.
+const autoscalerResource = "horizontalpodautoscalers.autoscaling"
+
+// UseAutoscalers applies the replicas of the workloads targeted by a HorizontalPodAutoscaler to
+// its bounds instead of their scale subresource, which the autoscaler would overwrite.
+// It must be called before the factory is started.
+func (c *Controller) UseAutoscalers(factory informers.SharedInformerFactory) {
+	informer := factory.Autoscaling().V1().HorizontalPodAutoscalers()
+	c.hpaLister = informer.Lister()
+	c.informersSynced = append(c.informersSynced, informer.Informer().HasSynced)
+	// the status of an autoscaler changes at every sync, only its target and bounds matter
+	enqueueAll := func(interface{}) { c.enqueueAll() }
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueueAll,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldHPA, oldOK := oldObj.(*autoscalingv1.HorizontalPodAutoscaler)
+			newHPA, newOK := newObj.(*autoscalingv1.HorizontalPodAutoscaler)
+			if oldOK && newOK && reflect.DeepEqual(oldHPA.Spec, newHPA.Spec) && reflect.DeepEqual(oldHPA.GetAnnotations(), newHPA.GetAnnotations()) {
+				return
+			}
+			enqueueAll(newObj)
+		},
+		DeleteFunc: enqueueAll,
+	})
+}
+
+// autoscalerOf returns the HorizontalPodAutoscaler targeting a workload, nil when there is none
+// or autoscalers aren't watched.
+func (c *Controller) autoscalerOf(w *scaling.Workload) (*autoscalingv1.HorizontalPodAutoscaler, error) {
+	if c.hpaLister == nil {
+		return nil, nil
+	}
+	hpas, err := c.hpaLister.HorizontalPodAutoscalers(w.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, hpa := range hpas {
+		ref := hpa.Spec.ScaleTargetRef
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			continue
+		}
+		if ref.Kind == w.GetKind() && ref.Name == w.GetName() && gv.Group == w.Resource.Group {
+			return hpa, nil
+		}
+	}
+	return nil, nil
+}
+
+// originalBounds returns the bounds recorded on a HorizontalPodAutoscaler before its first change.
+func originalBounds(hpa *autoscalingv1.HorizontalPodAutoscaler) (int32, int32, bool) {
+	annotations := hpa.GetAnnotations()
+	minValue, hasMin := annotations[originalMinReplicasAnnotation]
+	maxValue, hasMax := annotations[originalMaxReplicasAnnotation]
+	if !hasMin || !hasMax {
+		return 0, 0, false
+	}
+	minReplicas, minErr := strconv.ParseInt(minValue, 10, 32)
+	maxReplicas, maxErr := strconv.ParseInt(maxValue, 10, 32)
+	if minErr != nil || maxErr != nil || minReplicas < 1 || maxReplicas < minReplicas {
+		log.Println("func", "originalBounds", "Ignoring invalid original bounds of HorizontalPodAutoscaler", hpa.GetNamespace()+"/"+hpa.GetName(), minValue, maxValue)
+		return 0, 0, false
+	}
+	return int32(minReplicas), int32(maxReplicas), true
+}
+
+// minReplicasOf returns spec.minReplicas of a HorizontalPodAutoscaler, defaulting to 1 like the API server.
+func minReplicasOf(hpa *autoscalingv1.HorizontalPodAutoscaler) int32 {
+	if hpa.Spec.MinReplicas == nil {
+		return 1
+	}
+	return *hpa.Spec.MinReplicas
+}
+
+// changeAutoscaler applies a change of a workload targeted by a HorizontalPodAutoscaler: the
+// desired replicas become its maxReplicas, and its minReplicas when lower. The original bounds
+// are recorded on the first change and restored along with the original maxReplicas.
+// Failures are logged, counted and recorded as Events on the autoscaler.
+func (c *Controller) changeAutoscaler(change plannedChange) error {
+	hpa := change.hpa
+	originalMin, originalMax, saved := originalBounds(hpa)
+	restored := change.restore && saved && change.Desired == originalMax
+	if change.Desired == change.Current && !restored {
+		return nil
+	}
+
+	var resourceVersion string
+	annotations := map[string]interface{}{}
+	minReplicas := originalMin
+	switch {
+	case restored:
+		annotations[originalMinReplicasAnnotation] = nil
+		annotations[originalMaxReplicasAnnotation] = nil
+	case !saved:
+		originalMin = minReplicasOf(hpa)
+		annotations[originalMinReplicasAnnotation] = strconv.Itoa(int(originalMin))
+		annotations[originalMaxReplicasAnnotation] = strconv.Itoa(int(hpa.Spec.MaxReplicas))
+		// fail on a stale cache, so that bounds changed by the controller are never taken for the originals
+		resourceVersion = hpa.GetResourceVersion()
+		fallthrough
+	default:
+		minReplicas = originalMin
+		if change.Desired < minReplicas {
+			minReplicas = change.Desired
+		}
+	}
+	patch, err := mergePatch(resourceVersion, annotations, map[string]interface{}{"minReplicas": minReplicas, "maxReplicas": change.Desired})
+	if err != nil {
+		return err
+	}
+	_, err = c.clientSet.AutoscalingV1().HorizontalPodAutoscalers(hpa.GetNamespace()).Patch(context.TODO(), hpa.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	scaleOperations.WithLabelValues(hpa.GetNamespace(), autoscalerResource, string(change.Class), scaleResult(err)).Inc()
+	if err != nil {
+		log.Println("func", "changeAutoscaler", "Failed to bound HorizontalPodAutoscaler", hpa.GetNamespace()+"/"+hpa.GetName(), "to", minReplicas, change.Desired, "err:", err)
+		if !apierrors.IsNotFound(err) {
+			c.recorder.Eventf(hpa, corev1.EventTypeWarning, reasonScaleFailed, "Failed to set maxReplicas from %d to %d: %v", change.Current, change.Desired, err)
+		}
+		return err
+	}
+	fmt.Printf("Importance Factor %s) \t%s: maxReplicas of HorizontalPodAutoscaler %s %d -> %d, minReplicas %d\n", change.Class, change.Name, hpa.GetName(), change.Current, change.Desired, minReplicas)
+	c.recorder.Eventf(hpa, corev1.EventTypeNormal, reasonScaled, "Set maxReplicas from %d to %d and minReplicas to %d: %s", change.Current, change.Desired, minReplicas, change.Reason)
+	return nil
+}
+
+// restoreAutoscaler restores the original bounds of a HorizontalPodAutoscaler, if it has any.
+func (c *Controller) restoreAutoscaler(hpa *autoscalingv1.HorizontalPodAutoscaler) error {
+	originalMin, originalMax, saved := originalBounds(hpa)
+	if !saved {
+		return nil
+	}
+	patch, err := mergePatch("", map[string]interface{}{
+		originalMinReplicasAnnotation: nil,
+		originalMaxReplicasAnnotation: nil,
+	}, map[string]interface{}{"minReplicas": originalMin, "maxReplicas": originalMax})
+	if err != nil {
+		return err
+	}
+	_, err = c.clientSet.AutoscalingV1().HorizontalPodAutoscalers(hpa.GetNamespace()).Patch(context.TODO(), hpa.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
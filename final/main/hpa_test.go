@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/policy"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	autoscalinglisters "k8s.io/client-go/listers/autoscaling/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// autoscaler returns a HorizontalPodAutoscaler of namespace shop targeting the Deployment web.
+func autoscaler(minReplicas *int32, maxReplicas int32, annotations map[string]string) *autoscalingv1.HorizontalPodAutoscaler {
+	return &autoscalingv1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web", ResourceVersion: "1", Annotations: annotations},
+		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			MinReplicas:    minReplicas,
+			MaxReplicas:    maxReplicas,
+		},
+	}
+}
+
+func TestChangeAutoscaler(t *testing.T) {
+	original := map[string]string{originalMinReplicasAnnotation: "2", originalMaxReplicasAnnotation: "10"}
+	tests := []struct {
+		name            string
+		hpa             *autoscalingv1.HorizontalPodAutoscaler
+		desired         int32
+		restore         bool
+		wantMin         int32
+		wantMax         int32
+		wantAnnotations map[string]string
+	}{
+		{
+			name: "first change", hpa: autoscaler(int32Ptr(2), 10, nil), desired: 6,
+			wantMin: 2, wantMax: 6, wantAnnotations: original,
+		},
+		{
+			name: "below minReplicas", hpa: autoscaler(int32Ptr(2), 10, nil), desired: 1,
+			wantMin: 1, wantMax: 1, wantAnnotations: original,
+		},
+		{
+			name: "default minReplicas", hpa: autoscaler(nil, 10, nil), desired: 4,
+			wantMin: 1, wantMax: 4, wantAnnotations: map[string]string{originalMinReplicasAnnotation: "1", originalMaxReplicasAnnotation: "10"},
+		},
+		{
+			name: "later change", hpa: autoscaler(int32Ptr(1), 1, original), desired: 4,
+			wantMin: 2, wantMax: 4, wantAnnotations: original,
+		},
+		{
+			name: "restored", hpa: autoscaler(int32Ptr(2), 6, original), desired: 10, restore: true,
+			wantMin: 2, wantMax: 10, wantAnnotations: map[string]string{},
+		},
+		{
+			name: "unchanged", hpa: autoscaler(int32Ptr(2), 6, original), desired: 6,
+			wantMin: 2, wantMax: 6, wantAnnotations: original,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, recorder := newTestController(t, nil)
+			clientSet := fake.NewSimpleClientset(test.hpa)
+			c.clientSet = clientSet
+
+			change := plannedChange{Name: "web", Class: policy.Low, Current: test.hpa.Spec.MaxReplicas, Desired: test.desired, hpa: test.hpa, restore: test.restore}
+			if err := c.changeAutoscaler(change); err != nil {
+				t.Fatal(err)
+			}
+			got, err := clientSet.AutoscalingV1().HorizontalPodAutoscalers("shop").Get(context.TODO(), "web", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if minReplicasOf(got) != test.wantMin || got.Spec.MaxReplicas != test.wantMax {
+				t.Errorf("got bounds %d-%d, want %d-%d", minReplicasOf(got), got.Spec.MaxReplicas, test.wantMin, test.wantMax)
+			}
+			for _, key := range []string{originalMinReplicasAnnotation, originalMaxReplicasAnnotation} {
+				want, wantOK := test.wantAnnotations[key]
+				if value, ok := got.GetAnnotations()[key]; ok != wantOK || value != want {
+					t.Errorf("got annotations %v, want %s %q", got.GetAnnotations(), key, want)
+				}
+			}
+			recorded := events(recorder)
+			if changed := test.desired != test.hpa.Spec.MaxReplicas; changed != hasEvent(recorded, "Normal Scaled Set maxReplicas") {
+				t.Errorf("got events %q, want a Scaled event %t", recorded, changed)
+			}
+		})
+	}
+}
+
+func TestRestoreAutoscaler(t *testing.T) {
+	tests := []struct {
+		name    string
+		hpa     *autoscalingv1.HorizontalPodAutoscaler
+		wantMin int32
+		wantMax int32
+	}{
+		{
+			name:    "original bounds",
+			hpa:     autoscaler(int32Ptr(1), 3, map[string]string{originalMinReplicasAnnotation: "2", originalMaxReplicasAnnotation: "10"}),
+			wantMin: 2, wantMax: 10,
+		},
+		{name: "never changed", hpa: autoscaler(int32Ptr(1), 3, nil), wantMin: 1, wantMax: 3},
+		{
+			name:    "invalid original bounds",
+			hpa:     autoscaler(int32Ptr(1), 3, map[string]string{originalMinReplicasAnnotation: "5", originalMaxReplicasAnnotation: "2"}),
+			wantMin: 1, wantMax: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := newTestController(t, nil)
+			clientSet := fake.NewSimpleClientset(test.hpa)
+			c.clientSet = clientSet
+
+			if err := c.restoreAutoscaler(test.hpa); err != nil {
+				t.Fatal(err)
+			}
+			got, err := clientSet.AutoscalingV1().HorizontalPodAutoscalers("shop").Get(context.TODO(), "web", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if minReplicasOf(got) != test.wantMin || got.Spec.MaxReplicas != test.wantMax {
+				t.Errorf("got bounds %d-%d, want %d-%d", minReplicasOf(got), got.Spec.MaxReplicas, test.wantMin, test.wantMax)
+			}
+		})
+	}
+}
+
+func TestPlanClassAutoscaler(t *testing.T) {
+	setPolicy(&policy.Policy{APIVersion: policy.APIVersion, Status: policy.Black, Factor: policy.Factors{policy.Black: {policy.Low: 0}}})
+	c, _ := newTestController(t, nil, workload(deployments, "web", policy.Low, 7, nil))
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(autoscaler(int32Ptr(2), 10, nil))
+	c.hpaLister = autoscalinglisters.NewHorizontalPodAutoscalerLister(indexer)
+
+	changes, err := c.planClass(policy.Low)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	// the autoscaler is bounded rather than the workload scaled, never below a maxReplicas of 1
+	change := changes[0]
+	if change.HPA != "web" || change.Current != 10 || change.Desired != 1 || !strings.Contains(change.Reason, "bounded to 1") {
+		t.Errorf("got %s %d -> %d (%s), want hpa web 10 -> 1", change.HPA, change.Current, change.Desired, change.Reason)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// importanceLabel is the dedicated label that places a workload in an importance class.
+	importanceLabel = "kube-flux.io/importance"
+	// importanceAnnotation is the legacy annotation carrying the importance factor ("1", "2", "3").
+	importanceAnnotation = "imp"
+)
+
+// workloadImportance returns the importance class of a workload.
+// The kube-flux.io/importance label wins over the "imp" annotation, which is looked up
+// on the workload first and on its pod template second.
+func workloadImportance(w *scaling.Workload) (policy.Class, bool) {
+	return importance(w, w.TemplateAnnotations())
+}
+
+// importance looks up the importance class of an object and the annotations of its pod template.
+func importance(obj metav1.Object, templateAnnotations map[string]string) (policy.Class, bool) {
+	if value, ok := obj.GetLabels()[importanceLabel]; ok {
+		return parseImportance(value)
+	}
+	if value, ok := obj.GetAnnotations()[importanceAnnotation]; ok {
+		return parseImportance(value)
+	}
+	if value, ok := templateAnnotations[importanceAnnotation]; ok {
+		return parseImportance(value)
+	}
+	return "", false
+}
+
+// groupWorkloads groups the workloads carrying an importance label or annotation by importance
+// class, leaving out those scaled by their owner.
+func groupWorkloads(workloads []*scaling.Workload) map[policy.Class][]*scaling.Workload {
+	classes := make(map[policy.Class][]*scaling.Workload)
+	for _, w := range workloads {
+		if !w.Managed() {
+			continue
+		}
+		class, ok := workloadImportance(w)
+		if !ok {
+			continue
+		}
+		classes[class] = append(classes[class], w)
+	}
+	return classes
+}
+
+// parseImportance converts a label or annotation value into an importance class.
+// Both the class name (case-insensitive) and the legacy numeric factor are accepted.
+func parseImportance(value string) (policy.Class, bool) {
+	class, err := policy.ParseClass(value)
+	return class, err == nil
+}
@@ -4,69 +4,70 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/kube-flux/kube-flux/client/clientset/versioned"
+	"github.com/kube-flux/kube-flux/client/informers/externalversions"
+	"github.com/kube-flux/kube-flux/metrics"
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/scale"
 	clientcmd "k8s.io/client-go/tools/clientcmd/api"
 )
 
 var (
-	// cpuMap is a map stores importance factor and average CPU usage.
-	cpuMap = make(map[string]float64)
-	// memoryMap is a map stores importance factor and average memory usage.
-	memoryMap     = make(map[string]float64)
-	clientSet     *kubernetes.Clientset
-	policy        *Policy
-	currNamespace string
+	clientSet  *kubernetes.Clientset
+	currPolicy *policy.Policy
+	// policyLimits bound the factors accepted from PUT /policy and the EnergyPolicy.
+	policyLimits      = policy.DefaultLimits
+	policyLock        sync.RWMutex
+	policyBroadcaster = policy.NewBroadcaster(0)
+	controller        *Controller
+	// containerAggregator sums the usage of the containers of a pod, leaving out -exclude-containers.
+	containerAggregator metrics.Aggregator
+	// containerBreakdown prints the usage of every container, set by -container-usage.
+	containerBreakdown bool
+	// dryRun prints the replica changes instead of updating the workloads, set by -dry-run.
+	dryRun bool
 )
 
-// PodMetric stores the JSON Array of one single pod information.
-type PodMetric struct {
-	Kind       string `json:"kind"`
-	APIVersion string `json:"apiVersion"`
-	Metadata   struct {
-		Name              string    `json:"name"`
-		Namespace         string    `json:"namespace"`
-		SelfLink          string    `json:"selfLink"`
-		Imp               string    `json:"imp"`
-		CreationTimestamp time.Time `json:"creationTimestamp"`
-	} `json:"metadata"`
-	Timestamp  time.Time `json:"timestamp"`
-	Window     string    `json:"window"`
-	Containers []struct {
-		Name  string `json:"name"`
-		Usage struct {
-			CPU    string `json:"cpu"`
-			Memory string `json:"memory"`
-		} `json:"usage"`
-	} `json:"containers"`
-}
-
-var singlePodObj PodMetric // single pod structure object
-
-// authenticate is used to authenticate Go-client with GKE cluster.
-func authenticate(filePath string, HostIp string) *kubernetes.Clientset {
+// clusterConfig builds the REST config of a GKE cluster from its CA certificate and IP.
+func clusterConfig(filePath string, HostIp string) *rest.Config {
 	MasterUrl := "https://" + HostIp
 	ca, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		panic(err)
 	}
-	config := &rest.Config{
+	return &rest.Config{
 		TLSClientConfig: rest.TLSClientConfig{
 			CAData: ca,
 		},
 		Host:         MasterUrl,
 		AuthProvider: &clientcmd.AuthProviderConfig{Name: "gcp"}}
+}
+
+// authenticate is used to authenticate Go-client with GKE cluster.
+func authenticate(config *rest.Config, HostIp string) *kubernetes.Clientset {
 	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		panic("Failed to authenticate IP: " + HostIp)
@@ -74,278 +75,148 @@ func authenticate(filePath string, HostIp string) *kubernetes.Clientset {
 	return clientSet
 }
 
-// printDeploymentInfo prints the cpu and memory average of pods in each the importance factor.
-func printDeploymentInfo(clientSet *kubernetes.Clientset, namespace string) {
-	deployments, err := clientSet.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		panic(err)
-	}
-	// loop through all deployments
-	fmt.Printf("\n---------------------- [List of Deployment] ----------------------\n")
-	for i, dep := range deployments.Items {
-		currLabel := dep.GetLabels()["app"]
-		fmt.Printf("%d) Deployment: \t%s\n", i+1, dep.GetName())
-		fmt.Printf("   Label: \t%s\n", dep.GetLabels()["app"])
-		MapLabel := "app=" + currLabel
-		pods, _ := clientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: MapLabel})
-		numOfPods := len(pods.Items)
-		fmt.Printf("   Total Number of pods: %d\n", numOfPods)
-		currPodImp := ""
-		// loop through the pods
-		for j := range pods.Items {
-			currPod := pods.Items[j]
-			currPodImp = currPod.GetAnnotations()["imp"]
-			currPodName := currPod.GetName()
-			// calculate the sum of cpu and memory and save to map
-			err = sumPodUsage(currPodName, currPodImp, clientSet, namespace)
-			if err != nil {
-				panic(err.Error())
-			}
-		}
-		// get the ave of the pods in each deployment
-		if numOfPods != 0 {
-			cpuMap[currPodImp] = cpuMap[currPodImp] / float64(numOfPods)
-			memoryMap[currPodImp] = memoryMap[currPodImp] / float64(numOfPods)
-		}
-	}
-}
-
-// sumPodUsage print single pod info, then sums the cpu and memory usage of pods in the same importance factor.
-func sumPodUsage(podName string, imp string, clientSet *kubernetes.Clientset, namespace string) error {
-	absPath := "apis/metrics.k8s.io/v1beta1/namespaces/" + namespace + "/pods/" + podName
-	data, err := clientSet.RESTClient().Get().AbsPath(absPath).DoRaw(context.TODO())
-	if err != nil {
-		return err
-	}
-	err = json.Unmarshal(data, &singlePodObj)
-	if err != nil {
-		panic(err.Error())
-	}
-
-	tempMemoryString := strings.TrimRight(singlePodObj.Containers[0].Usage.Memory, "Ki")
-	tempCPUString := strings.TrimRight(singlePodObj.Containers[0].Usage.CPU, "n")
-	currentCPUUsage, _ := strconv.ParseFloat(tempCPUString, 2)
-	currentMemoryUsage, _ := strconv.ParseFloat(tempMemoryString, 2)
-
-	fmt.Printf("   Pod name: \t\t\t%s\n", podName)
-	fmt.Printf("   Current imp: \t\t%s\n", imp)
-	fmt.Printf("   Current CPU usage: \t\t%.2f\n", currentCPUUsage)
-	fmt.Printf("   Current Memory usage: \t%.2f\n", currentMemoryUsage)
-	//sum the CPU & memory usage in the same imp
-	cpuMap[imp] += currentCPUUsage
-	memoryMap[imp] += currentMemoryUsage
-	return err
-}
-
-// changeReplica changes the number of replica-sets of a certain deployment.
-func changeReplica(clientSet *kubernetes.Clientset, namespace string, imp int32, num int32, action string) {
-	deployment, err := clientSet.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		panic(err)
-	}
-
-	nginx := deployment.Items[imp-1]
-	currReplicaNum := *(nginx.Spec.Replicas)
-	fmt.Printf("Importance Factor %d) \tPrevious number of replica-set deployed: %d\n", imp, currReplicaNum)
+// changeReplica changes the number of replica-sets of a workload through its scale subresource.
+// An empty action applies the desired replicas as is, "add" only scales up and "subtract" only
+// scales down. Failures are logged, counted and recorded as Events on the workload.
+func (c *Controller) changeReplica(change plannedChange) error {
+	w, class, num, action := change.workload, change.Class, change.Desired, change.action
+	currReplicaNum := w.Replicas()
+	fmt.Printf("Importance Factor %s) \t%s: Previous number of replica-set deployed: %d\n", class, w.GetName(), currReplicaNum)
 	var newReplicaNum int32
 
 	if currReplicaNum == num {
 		fmt.Printf("\t\t\tNothing need to be changed.\n")
-		return
+		return nil
 	}
-	if currReplicaNum > num && action == "subtract" {
+	if currReplicaNum > num && (action == "subtract" || action == "") {
 		newReplicaNum = num
 		fmt.Printf("\t\t\tOff %d replica-set.\n", currReplicaNum-num)
-	} else if currReplicaNum < num && action == "add" {
+	} else if currReplicaNum < num && (action == "add" || action == "") {
 		newReplicaNum = num
 		fmt.Printf("\t\t\tOn %d replica-set.\n", num-currReplicaNum)
 	} else {
 		fmt.Printf("\t\t\tNothing need to be changed.\n")
-		return
+		return nil
 	}
-	// update the replica-set number
-	*(nginx.Spec.Replicas) = newReplicaNum
-	_, _ = clientSet.AppsV1().Deployments(namespace).Update(context.TODO(), &nginx, metav1.UpdateOptions{})
-	fmt.Printf("\t\t\tCurrent number replica-set after change: %d\n", *(nginx.Spec.Replicas))
-}
 
-// ChangeReplicaPolicy changes the number of replica-sets of a certain deployment.
-func ChangeReplicaPolicy(clientSet *kubernetes.Clientset, policy *Policy) {
-	deployment, err := clientSet.AppsV1().Deployments(currNamespace).List(context.TODO(), metav1.ListOptions{})
+	resource := w.Resource.GroupResource()
+	previous, err := scaling.SetScale(context.TODO(), c.scales, resource, w.GetNamespace(), w.GetName(), newReplicaNum)
+	scaleOperations.WithLabelValues(w.GetNamespace(), resource.String(), string(class), scaleResult(err)).Inc()
 	if err != nil {
-		panic(err)
+		log.Println("func", "changeReplica", "Failed to scale", w.Key(), "to", newReplicaNum, "err:", err)
+		if !apierrors.IsNotFound(err) {
+			c.recorder.Eventf(w, corev1.EventTypeWarning, reasonScaleFailed, "Failed to scale from %d to %d replicas: %v", currReplicaNum, newReplicaNum, err)
+		}
+		return err
 	}
-	d1 := deployment.Items[0]
-	d2 := deployment.Items[1]
-	d3 := deployment.Items[2]
-	fmt.Printf("Number of replica-set deployed currently : %d %d %d\n ", *(d1.Spec.Replicas), *(d2.Spec.Replicas), *(d3.Spec.Replicas))
-
-	high := policy.Factor[policy.Status]["High"]
-	medium := policy.Factor[policy.Status]["Medium"]
-	low := policy.Factor[policy.Status]["Low"]
-	fmt.Printf("Changing the # of replica-set to top/medium/low %d %d %d\n", high, medium, low)
-	*(d1.Spec.Replicas) = high
-	*(d2.Spec.Replicas) = medium
-	*(d3.Spec.Replicas) = low
-	_, _ = clientSet.AppsV1().Deployments(currNamespace).Update(context.TODO(), &d1, metav1.UpdateOptions{})
-	_, _ = clientSet.AppsV1().Deployments(currNamespace).Update(context.TODO(), &d2, metav1.UpdateOptions{})
-	_, _ = clientSet.AppsV1().Deployments(currNamespace).Update(context.TODO(), &d3, metav1.UpdateOptions{})
-	fmt.Printf("Number of replica-set deployed after change : %d %d %d\n", high, medium, low)
+	c.recorder.Eventf(w, corev1.EventTypeNormal, reasonScaled, "Scaled from %d to %d replicas: %s", previous, newReplicaNum, change.Reason)
+	fmt.Printf("\t\t\tCurrent number replica-set after change: %d\n", newReplicaNum)
+	return nil
+}
 
+// requireResource exits when the cluster doesn't serve a resource, whose informer would never
+// sync, telling which flag disables the feature needing it.
+func requireResource(groupVersion string, resource string, disable string) {
+	resources, err := clientSet.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Fatalln("Failed to discover", groupVersion, "err:", err)
+	}
+	if err == nil {
+		for _, r := range resources.APIResources {
+			if r.Name == resource {
+				return
+			}
+		}
+	}
+	log.Fatalln("The cluster doesn't serve", resource, "in", groupVersion, "(Kubernetes 1.21 or later), run with", disable)
 }
 
-// printCurrPodUsage loops through CPU map and memory map to print.
-func printCurrPodUsage() {
-	// cpuMap["1"] is cpuMap which key is imp "1"  memoryMap["1"] is memoryMap which key is imp "1"
-	for key := range cpuMap {
-		fmt.Printf("Importance Factor %s) \tAverage of CPU: \t%.2f\n\t\t\tAverage of Memory: \t%.2f\n", key, cpuMap[key], memoryMap[key])
+// allowedReplicas returns num when action allows scaling from current to num, current otherwise.
+func allowedReplicas(current int32, num int32, action string) int32 {
+	if (num > current && action == scaling.ActionSubtract) || (num < current && action == scaling.ActionAdd) {
+		return current
 	}
+	return num
 }
 
-// autoAdjustReplica changes the replica-set num based on CPU and memory usage.
-func autoAdjustReplica(clientSet *kubernetes.Clientset, namespace string) (factor map[string]map[string]int32) {
-	fmt.Printf("\n--------------------- [Change of Relica-set] ---------------------\n")
-	fmt.Println("Tong", cpuMap["1"], cpuMap["2"], cpuMap["3"])
-	if cpuMap["1"] > 1000000 {
-		// if too high, subtract down
-		fmt.Printf("Subtracting...\n")
-		if policy.Status == "Green" {
-			changeReplica(clientSet, namespace, 3, 3, "subtract")
-			changeReplica(clientSet, namespace, 2, 3, "subtract")
-			changeReplica(clientSet, namespace, 1, 10, "subtract")
-		} else if policy.Status == "Yellow" {
-			changeReplica(clientSet, namespace, 3, 2, "subtract")
-			changeReplica(clientSet, namespace, 2, 2, "subtract")
-			changeReplica(clientSet, namespace, 1, 8, "subtract")
-		} else {
-			changeReplica(clientSet, namespace, 3, 1, "subtract")
-			changeReplica(clientSet, namespace, 2, 1, "subtract")
-			changeReplica(clientSet, namespace, 1, 3, "subtract")
+// autoAdjustReplica picks the replica factors and the scaling directions by matching the average
+// CPU and memory usage of every class against the scaling rules. Classes without a matching band
+// keep their factor in current.
+func autoAdjustReplica(rules *scaling.RuleSet, current policy.Factors, usage map[policy.Class]metrics.Usage) (policy.Factors, *scaling.Targets) {
+	targets := rules.Evaluate(usage)
+
+	factor := current.DeepCopy()
+	if factor == nil {
+		factor = make(policy.Factors)
+	}
+	for status, classes := range targets.Factors {
+		if factor[status] == nil {
+			factor[status] = make(map[policy.Class]int32)
 		}
-		green := map[string]int32{"High": 10, "Medium": 3, "Low": 3}
-		yellow := map[string]int32{"High": 8, "Medium": 2, "Low": 2}
-		red := map[string]int32{"High": 3, "Medium": 1, "Low": 1}
-		factor = map[string]map[string]int32{"Green": green, "Yellow": yellow, "Red": red}
-	} else if cpuMap["1"] < 100 {
-		// if too low, scale up
-		fmt.Printf("Adding...\n")
-		if policy.Status == "Green" {
-			changeReplica(clientSet, namespace, 3, 10, "add")
-			changeReplica(clientSet, namespace, 2, 10, "add")
-			changeReplica(clientSet, namespace, 1, 10, "add")
-		} else if policy.Status == "Yellow" {
-			changeReplica(clientSet, namespace, 3, 8, "add")
-			changeReplica(clientSet, namespace, 2, 8, "add")
-			changeReplica(clientSet, namespace, 1, 8, "add")
-		} else {
-			changeReplica(clientSet, namespace, 3, 3, "add")
-			changeReplica(clientSet, namespace, 2, 3, "add")
-			changeReplica(clientSet, namespace, 1, 3, "add")
+		for class, replicas := range classes {
+			factor[status][class] = replicas
 		}
-		green := map[string]int32{"High": 10, "Medium": 10, "Low": 10}
-		yellow := map[string]int32{"High": 8, "Medium": 8, "Low": 8}
-		red := map[string]int32{"High": 3, "Medium": 3, "Low": 3}
-		factor = map[string]map[string]int32{"Green": green, "Yellow": yellow, "Red": red}
-	} else {
-		if policy.Status == "Green" {
-			changeReplica(clientSet, namespace, 3, 6, "add")
-			changeReplica(clientSet, namespace, 2, 6, "add")
-			changeReplica(clientSet, namespace, 1, 10, "add")
-		} else if policy.Status == "Yellow" {
-			changeReplica(clientSet, namespace, 3, 4, "add")
-			changeReplica(clientSet, namespace, 2, 4, "add")
-			changeReplica(clientSet, namespace, 1, 8, "add")
-		} else {
-			changeReplica(clientSet, namespace, 3, 2, "add")
-			changeReplica(clientSet, namespace, 2, 2, "add")
-			changeReplica(clientSet, namespace, 1, 3, "add")
-		}
-		green := map[string]int32{"High": 10, "Medium": 6, "Low": 6}
-		yellow := map[string]int32{"High": 8, "Medium": 4, "Low": 4}
-		red := map[string]int32{"High": 3, "Medium": 2, "Low": 2}
-		factor = map[string]map[string]int32{"Green": green, "Yellow": yellow, "Red": red}
 	}
-	return factor
+	return factor, targets
 }
 
-// aveCurrPodUsage calculate the average cpu and memory usage of pods in the same importance factor.
-func aveCurrPodUsage(clientSet *kubernetes.Clientset, namespace string) error {
-	deployments, err := clientSet.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		panic(err)
+// printContainerUsage prints the usage of every container of a pod when -container-usage is set.
+func printContainerUsage(usage metrics.PodUsage) {
+	if !containerBreakdown {
+		return
 	}
-
-	// clear map before recalculate
-	for key, _ := range cpuMap {
-		cpuMap[key] = 0
-	}
-	// loop through all deployments
-	for _, dep := range deployments.Items {
-		currLabel := dep.GetLabels()["app"]
-		MapLabel := "app=" + currLabel
-		pods, _ := clientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: MapLabel})
-		numOfPods := len(pods.Items)
-		currPodImp := ""
-		// loop through the pods
-		for j := range pods.Items {
-			currPod := pods.Items[j]
-			currPodImp = currPod.GetAnnotations()["imp"]
-			currPodName := currPod.GetName()
-			// calculate the average of cpu and memory and save to map
-			absPath := "apis/metrics.k8s.io/v1beta1/namespaces/" + namespace + "/pods/" + currPodName
-			data, err := clientSet.RESTClient().Get().AbsPath(absPath).DoRaw(context.TODO())
-			if err != nil {
-				return err
-			}
-			err = json.Unmarshal(data, &singlePodObj)
-			if err != nil {
-				panic(err.Error())
-			}
-			if err != nil {
-				panic(err.Error())
-			}
-			tempMemoryString := strings.TrimRight(singlePodObj.Containers[0].Usage.Memory, "Ki")
-			tempCPUString := strings.TrimRight(singlePodObj.Containers[0].Usage.CPU, "n")
-			currentCPUUsage, _ := strconv.ParseFloat(tempCPUString, 2)
-			currentMemoryUsage, _ := strconv.ParseFloat(tempMemoryString, 2)
-			//sum the CPU & memory usage in the same imp
-			cpuMap[currPodImp] += currentCPUUsage
-			memoryMap[currPodImp] += currentMemoryUsage
-		}
-		if numOfPods != 0 {
-			cpuMap[currPodImp] = cpuMap[currPodImp] / float64(numOfPods)
-			memoryMap[currPodImp] = memoryMap[currPodImp] / float64(numOfPods)
-		} else {
-			cpuMap[currPodImp] = 0
-			memoryMap[currPodImp] = 0
-		}
+	for _, container := range usage.Containers {
+		fmt.Printf("     Container %s: \tCPU %dn, Memory %d bytes\n", container.Name, container.CPU, container.Memory)
 	}
-	return err
 }
 
 // ----------------- policy -----------------
 
-type Policy struct {
-	Status string
-	Factor map[string]map[string]int32
+// publishPolicy bumps the revision of currPolicy and sends it to watchers.
+// policyLock must be held for writing.
+func publishPolicy() {
+	currPolicy.Revision++
+	policyBroadcaster.Publish(currPolicy.Revision, servedPolicy())
+}
+
+// servedPolicy returns a copy of currPolicy as served by GET /policy and its watch. When several
+// namespaces are managed, each one scales with the factors derived from its own usage or set by
+// its override, so the factors of the policy are left out instead of being shown as the ones in
+// use. policyLock must be held.
+func servedPolicy() *policy.Policy {
+	p := currPolicy.DeepCopy()
+	if controller != nil && controller.namespaceLister != nil {
+		p.Factor = nil
+	}
+	return p
 }
 
 // backend handles policy & importance factor
 func Backend(w http.ResponseWriter, req *http.Request) {
-	// Setup response
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS, PUT")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-
-	if req.Method == "OPTIONS" {
+	// CORS and OPTIONS are handled by policy.Auth
+	if req.Method == "GET" && policy.IsWatch(req) {
+		log.Println("func", "ServeHTTP", "Handling watch request /policy")
+		policy.ServeWatch(w, req, policyBroadcaster, func() (*policy.Policy, error) {
+			policyLock.RLock()
+			defer policyLock.RUnlock()
+			return servedPolicy(), nil
+		})
 		return
 	}
 
 	if req.Method == "GET" {
 		log.Println("func", "ServeHTTP", "Handling GET request /policy")
 
+		policyLock.RLock()
+		current := servedPolicy()
+		policyLock.RUnlock()
+		w.Header().Set("ETag", policy.ETag(current.Revision))
+		if policy.MatchesETag(req.Header.Get("If-None-Match"), current.Revision) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(policy)
+		json.NewEncoder(w).Encode(current)
 
 		log.Println("func", "ServeHTTP", "Handled GET request for /policy")
 		return
@@ -353,66 +224,252 @@ func Backend(w http.ResponseWriter, req *http.Request) {
 
 	if req.Method == "PUT" {
 		log.Println("func", "ServeHTTP", "Handling PUT request")
-		w.WriteHeader(http.StatusNoContent)
 
-		log.Println("func", "NewPolicyHandler", "Decoding request body")
-		decoder := json.NewDecoder(req.Body)
-		var request Policy
-		if err := decoder.Decode(&request); err != nil {
-			err := errors.New("failed to decode to Policy")
+		log.Println("func", "ServeHTTP", "Decoding request body")
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			log.Println("func", "ServeHTTP", "Failed to read request body", "err:", err)
+			policy.WriteProblem(w, req, http.StatusBadRequest, "Failed to read the request body.")
+			return
+		}
+		// v0 payloads, e.g. {"Status": "Yellow", "Factor": "null"} from the UI, are converted by Decode
+		request, err := policy.Decode(body)
+		if err != nil {
 			log.Println("func", "ServeHTTP", "decode policy from request err:", err)
+			policy.WriteProblem(w, req, http.StatusBadRequest, "Failed to decode the policy: "+err.Error())
+			return
 		}
-
-		if policy.Status == request.Status {
-			log.Println("func", "ServeHTTP", "Same status")
+		if err := request.Validate(policyLimits); err != nil {
+			log.Println("func", "ServeHTTP", "err:", err)
+			policy.WriteValidationProblem(w, req, err)
 			return
 		}
 
-		policy.Status = request.Status
-		ChangeReplicaPolicy(clientSet, policy)
+		if err := controller.SetPolicy(request, req.Header.Get("If-Match")); err != nil {
+			log.Println("func", "ServeHTTP", "Failed to set policy", "err:", err)
+			if errors.Is(err, errReadOnlyPolicy) {
+				w.Header().Set("Allow", "GET, OPTIONS")
+				policy.WriteProblem(w, req, http.StatusMethodNotAllowed, "The policy is read from "+controller.source.String()+", change it there.")
+				return
+			}
+			if errors.Is(err, errDryRun) {
+				// 409 is left to concurrent modifications, which a retry may resolve
+				w.Header().Set("Allow", "GET, OPTIONS")
+				policy.WriteProblem(w, req, http.StatusMethodNotAllowed, "The controller runs with -dry-run and doesn't change the EnergyPolicy, change it with kubectl instead.")
+				return
+			}
+			if errors.Is(err, policy.ErrPreconditionFailed) {
+				policy.WriteProblem(w, req, http.StatusPreconditionFailed, "The policy was changed since the revision in If-Match, get it and retry.")
+				return
+			}
+			if apierrors.IsConflict(err) {
+				policy.WriteProblem(w, req, http.StatusConflict, "The EnergyPolicy was modified concurrently, retry the request.")
+				return
+			}
+			policy.WriteProblem(w, req, http.StatusInternalServerError, "Failed to apply the policy.")
+			return
+		}
+		policyLock.RLock()
+		w.Header().Set("ETag", policy.ETag(currPolicy.Revision))
+		policyLock.RUnlock()
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-}
-
-func monitor() {
-	for {
-		//printDeploymentInfo(clientSet, currNamespace) //Print the usage of CPU memory in each imp
-		// calculate the usage and print
-		//fmt.Printf("\n----------------------------- [Usage] ----------------------------\n")
-		//printCurrPodUsage()
-		// calculate average usage and print again
-		aveCurrPodUsage(clientSet, currNamespace)
-		// change the replica-set num accordingly
-		policy.Factor = autoAdjustReplica(clientSet, currNamespace)
-		// calling Sleep method
-		fmt.Println("\nWaiting for changing the relica-set number.....")
-		time.Sleep(10 * time.Second)
-		fmt.Println("Done!")
 
-		//fmt.Printf("\n---------------------- [Usage after change] ----------------------\n")
-		//printCurrPodUsage()
-	}
+	w.Header().Set("Allow", "GET, OPTIONS, PUT")
+	policy.WriteProblem(w, req, http.StatusMethodNotAllowed, "Method "+req.Method+" is not supported.")
 }
 
 // curl -X PUT -H "Content-Type: application/json" -d '{"Red": {"TOP": 1, "Medium": 1, "LOW": 0}, "Yellow": {"TOP": 2, "Medium": 2, "LOW": 0}, "Green": {"TOP": 4, "Medium": 4, "LOW": 0}}' http://localhost:8888/factor
+//
+// "main plan [flags] $PEMPATH <CLUSTER_IP_ADDRESS> <NAMESPACE>" prints the replica changes the current
+// policy and metrics would cause and exits, without changing anything.
+//
+// With -namespace-selector or -all-namespaces, NAMESPACE only holds the EnergyPolicy, "default" when omitted.
 func main() {
-	filePath := os.Args[1]                        //Pass .pem file as a command line argument
-	clusterIP := os.Args[2]                       //Pass cluster IP address
-	currNamespace = os.Args[3]                    //Pass the namespace
-	clientSet = authenticate(filePath, clusterIP) //Authenticates with the GCP cluster
+	args := os.Args[1:]
+	plan := len(args) > 0 && args[0] == "plan"
+	if plan {
+		args = args[1:]
+	}
+	metricsResync := flag.Duration("metrics-resync", 10*time.Second, "How often pod metrics are fetched and replica factors re-evaluated")
+	informerResync := flag.Duration("informer-resync", 10*time.Minute, "Resync period of the workload and Pod informers")
+	scaleKinds := flag.String("scale-kinds", scaling.DefaultTargetKinds, "Comma-separated apiVersion/Kind of the workloads to scale, any resource with a scale subresource, e.g. apps/v1/Deployment,apps/v1/StatefulSet,argoproj.io/v1alpha1/Rollout")
+	workers := flag.Int("workers", 2, "Number of workers reconciling importance classes")
+	namespaceSelector := flag.String("namespace-selector", "", "Label selector of the namespaces to manage instead of NAMESPACE, e.g. kube-flux.io/managed=true")
+	allNamespaces := flag.Bool("all-namespaces", false, "Manage every namespace instead of NAMESPACE")
+	policySource := flag.String("policy-source", "", "Where the policy comes from: embedded, crd, a Zeus URL or file:<path>; crd when -energy-policy is set, embedded otherwise")
+	zeusTokenFile := flag.String("zeus-token-file", "", "File of the bearer token sent to Zeus with a Zeus -policy-source, read again at every request, e.g. a projected service account token")
+	energyPolicy := flag.String("energy-policy", "", "Name of the EnergyPolicy in the namespace to reconcile from with -policy-source=crd")
+	maxReplicas := flag.Int("max-replicas", policy.DefaultMaxReplicas, "Highest replica count a policy factor may set")
+	scalingRules := flag.String("scaling-rules", "", "YAML file of the scaling rules of every status and class, reloaded when it changes; the rules of the EnergyPolicy take precedence")
+	excludeContainers := flag.String("exclude-containers", "", "Comma-separated names of containers left out of pod usage, e.g. istio-proxy")
+	flag.BoolVar(&containerBreakdown, "container-usage", false, "Print the usage of every container of the evaluated pods")
+	flag.BoolVar(&dryRun, "dry-run", false, "Print the replica changes instead of updating the workloads")
+	output := flag.String("output", outputTable, "Format of the plan subcommand: table or json")
+	var behavior scaling.Behavior
+	behavior.AddFlags(flag.CommandLine)
+	releaseStatus := flag.String("release-status", string(policy.Green), "Unconstrained status under which the baseline replicas recorded before the first energy-driven change are restored instead of applying factors; empty to apply the factors of every status")
+	respectPDBs := flag.Bool("respect-disruption-budgets", true, "Never scale a workload down below the minAvailable or by more than the maxUnavailable of the PodDisruptionBudgets covering its pods")
+	adjustHPAs := flag.Bool("adjust-hpas", true, "Set the maxReplicas of the HorizontalPodAutoscaler targeting a workload instead of its replicas, which the autoscaler would overwrite")
+	var suspension scaling.Suspension
+	suspension.AddFlags(flag.CommandLine)
+	var metricsOptions metrics.Options
+	metricsOptions.AddFlags(flag.CommandLine)
+	var authOptions policy.AuthOptions
+	authOptions.AddFlags(flag.CommandLine)
+	flag.CommandLine.Parse(args)
+
+	policyLimits.MaxReplicas = int32(*maxReplicas)
+	if *output != outputTable && *output != outputJSON {
+		log.Fatalln("Invalid -output", *output, "expected", outputTable, "or", outputJSON)
+	}
+	if err := behavior.Validate(); err != nil {
+		log.Fatalln("Invalid scaling behavior", "err:", err)
+	}
+	kinds, err := scaling.ParseTargetKinds(*scaleKinds)
+	if err != nil {
+		log.Fatalln("Invalid -scale-kinds", "err:", err)
+	}
+	var unconstrained policy.Status
+	if *releaseStatus != "" {
+		if unconstrained, err = policy.ParseStatus(*releaseStatus); err != nil {
+			log.Fatalln("Invalid -release-status", "err:", err)
+		}
+	}
+	// selector selects the managed namespaces, nil to only manage namespace
+	var selector labels.Selector
+	switch {
+	case *allNamespaces && *namespaceSelector != "":
+		log.Fatalln("-all-namespaces and -namespace-selector are exclusive")
+	case *allNamespaces:
+		selector = labels.Everything()
+	case *namespaceSelector != "":
+		if selector, err = labels.Parse(*namespaceSelector); err != nil {
+			log.Fatalln("Invalid -namespace-selector", "err:", err)
+		}
+	}
+	containerAggregator = metrics.ParseExclude(*excludeContainers)
+	filePath := flag.Arg(0)  //Pass .pem file as a command line argument
+	clusterIP := flag.Arg(1) //Pass cluster IP address
+	namespace := flag.Arg(2) //Pass the namespace
+	// the informers watch every namespace when several are managed
+	watchNamespace := namespace
+	if selector != nil {
+		watchNamespace = metav1.NamespaceAll
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+	}
+	config := clusterConfig(filePath, clusterIP)
+	clientSet = authenticate(config, clusterIP) //Authenticates with the GCP cluster
 
-	green := map[string]int32{"High": 10, "Medium": 10, "Low": 10}
-	yellow := map[string]int32{"High": 8, "Medium": 8, "Low": 8}
-	red := map[string]int32{"High": 3, "Medium": 3, "Low": 3}
-	initFactor := map[string]map[string]int32{"Green": green, "Yellow": yellow, "Red": red}
-	policy = &Policy{
-		Status: "Green",
-		Factor: initFactor,
+	if *policySource == "" {
+		*policySource = sourceEmbedded
+		if *energyPolicy != "" {
+			*policySource = sourceCRD
+		}
+	}
+	if *policySource == sourceCRD && *energyPolicy == "" {
+		*energyPolicy = "default"
 	}
 
-	go monitor()
-	http.HandleFunc("/policy", Backend)
+	currPolicy = policy.Default()
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientSet, *informerResync, informers.WithNamespace(watchNamespace))
+	metricsSource, err := metricsOptions.NewSource(config, containerAggregator)
+	if err != nil {
+		log.Fatalln("Failed to create metrics source", "err:", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Fatalln("Failed to create dynamic client", "err:", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientSet.Discovery()))
+	resources, err := scaling.ResolveTargetKinds(mapper, kinds)
+	if err != nil {
+		log.Fatalln("Invalid -scale-kinds", "err:", err)
+	}
+	scales, err := scale.NewForConfig(config, mapper, dynamic.LegacyAPIPathResolverFunc, scale.NewDiscoveryScaleKindResolver(clientSet.Discovery()))
+	if err != nil {
+		log.Fatalln("Failed to create scale client", "err:", err)
+	}
+	dynamicFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, *informerResync, watchNamespace, nil)
+	controller = NewController(clientSet, factory, dynamicClient, dynamicFactory, scales, resources, metricsSource, namespace, *metricsResync)
+	if selector != nil {
+		controller.UseNamespaces(factory, selector)
+	}
+	if !plan {
+		// a plan has no history to stabilize
+		controller.UseScalingBehavior(behavior)
+	}
+	controller.UseReleaseStatus(unconstrained)
+	if *respectPDBs {
+		requireResource("policy/v1", "poddisruptionbudgets", "-respect-disruption-budgets=false")
+		controller.UseDisruptionBudgets(factory)
+	}
+	if *adjustHPAs {
+		controller.UseAutoscalers(factory)
+	}
+	if suspension.Enabled() {
+		requireResource("batch/v1", "cronjobs", "no -suspend-classes")
+		controller.UseSuspension(suspension, factory)
+	}
+	stopCh := make(chan struct{})
+	if *scalingRules != "" {
+		if err := controller.WatchScalingRules(*scalingRules, stopCh); err != nil {
+			log.Fatalln("Invalid -scaling-rules", "err:", err)
+		}
+	}
+	if selector != nil && *policySource != sourceCRD {
+		log.Println("The EnergyPolicies of the managed namespaces only override the policy with -policy-source=crd, they are ignored")
+	}
+	var fluxClient versioned.Interface
+	switch *policySource {
+	case sourceEmbedded:
+	case sourceCRD:
+		fluxClient, err = versioned.NewForConfig(config)
+		if err != nil {
+			log.Fatalln("Failed to create kube-flux client", "err:", err)
+		}
+		// the EnergyPolicies of the managed namespaces override the policy there
+		fluxFactory := externalversions.NewSharedInformerFactoryWithOptions(fluxClient, *informerResync, externalversions.WithNamespace(watchNamespace))
+		controller.WatchEnergyPolicy(fluxClient, fluxFactory, *energyPolicy)
+		fluxFactory.Start(stopCh)
+	default:
+		source, err := newPolicySource(*policySource, *zeusTokenFile)
+		if err != nil {
+			log.Fatalln("Invalid -policy-source", "err:", err)
+		}
+		controller.UsePolicySource(source)
+		log.Println("Reading the policy from", source)
+	}
+	factory.Start(stopCh)
+	dynamicFactory.Start(stopCh)
+	if plan {
+		err := runPlan(controller, fluxClient, *energyPolicy, os.Stdout, *output, stopCh)
+		close(stopCh)
+		if err != nil {
+			log.Fatalln("Failed to plan", "err:", err)
+		}
+		return
+	}
+	auth, err := authOptions.NewAuth(clientSet)
+	if err != nil {
+		log.Fatalln("Failed to configure policy API authentication", "err:", err)
+	}
+	if dryRun {
+		log.Println("Dry run, replica changes are printed and not applied")
+	}
+	go func() {
+		if err := controller.Run(*workers, stopCh); err != nil {
+			log.Fatalln("Failed to run controller", "err:", err)
+		}
+	}()
+
+	http.Handle("/policy", auth.Handler(http.HandlerFunc(Backend)))
+	http.Handle("/release", auth.Handler(http.HandlerFunc(ReleaseBackend)))
+	http.Handle("/metrics", promhttp.Handler())
 	http.ListenAndServe(":8888", nil)
 
 }
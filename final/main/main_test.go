@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// getPolicy decodes the policy of GET /policy.
+func getPolicy(t *testing.T) *policy.Policy {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Backend(rec, httptest.NewRequest("GET", "/policy", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	var p policy.Policy
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	return &p
+}
+
+// watchPolicy decodes the first event of GET /policy?watch=true.
+func watchPolicy(t *testing.T) *policy.Policy {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(Backend))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/policy?watch=true", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data := strings.TrimPrefix(scanner.Text(), "data:"); data != scanner.Text() {
+			var p policy.Policy
+			if err := json.Unmarshal([]byte(data), &p); err != nil {
+				t.Fatal(err)
+			}
+			return &p
+		}
+	}
+	t.Fatalf("the watch ended without an event: %v", scanner.Err())
+	return nil
+}
+
+func TestServedPolicyFactors(t *testing.T) {
+	defer func(c *Controller) { controller = c }(controller)
+	tests := []struct {
+		name        string
+		controller  *Controller
+		wantFactors bool
+	}{
+		{name: "namespace", controller: &Controller{namespace: "default"}, wantFactors: true},
+		{
+			name:       "several namespaces",
+			controller: &Controller{namespace: "default", namespaceLister: corelisters.NewNamespaceLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}))},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			controller = test.controller
+			policyLock.Lock()
+			currPolicy = policy.Default()
+			policyLock.Unlock()
+
+			for source, p := range map[string]*policy.Policy{"GET": getPolicy(t), "watch": watchPolicy(t)} {
+				if p.Status != policy.Green {
+					t.Errorf("%s: got status %s, want Green", source, p.Status)
+				}
+				if hasFactors := p.Factor != nil; hasFactors != test.wantFactors {
+					t.Errorf("%s: got factors %v, want factors %t", source, p.Factor, test.wantFactors)
+				}
+			}
+		})
+	}
+}
+
+func TestChangeReplica(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     int32
+		desired     int32
+		action      string
+		conflicting int
+		err         error
+		wantErr     bool
+		wantReplica int32
+		wantEvent   string
+	}{
+		{name: "scale down", current: 4, desired: 2, wantReplica: 2, wantEvent: "Normal Scaled Scaled from 4 to 2 replicas"},
+		{name: "scale up", current: 2, desired: 4, wantReplica: 4, wantEvent: "Normal Scaled Scaled from 2 to 4 replicas"},
+		{name: "unchanged", current: 3, desired: 3, wantReplica: 3},
+		{name: "add only", current: 4, desired: 2, action: "add", wantReplica: 4},
+		{name: "subtract only", current: 2, desired: 4, action: "subtract", wantReplica: 2},
+		{name: "conflict retried", current: 4, desired: 2, conflicting: 2, wantReplica: 2, wantEvent: "Normal Scaled"},
+		{
+			name: "failure", current: 4, desired: 2, err: fmt.Errorf("admission webhook denied the request"),
+			wantErr: true, wantReplica: 4, wantEvent: "Warning ScaleFailed Failed to scale from 4 to 2 replicas",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := workload(deployments, "web", policy.Low, int64(test.current), nil)
+			scales := &testScales{replicas: map[string]int32{"shop/web": test.current}, conflicting: test.conflicting, err: test.err}
+			c, recorder := newTestController(t, scales, obj)
+			w, _ := scaling.NewWorkload(deployments, obj)
+
+			err := c.changeReplica(plannedChange{Class: policy.Low, Desired: test.desired, Reason: "factor of status Black", workload: w, action: test.action})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %t", err, test.wantErr)
+			}
+			if got := scales.replicas["shop/web"]; got != test.wantReplica {
+				t.Errorf("got %d replicas, want %d", got, test.wantReplica)
+			}
+			recorded := events(recorder)
+			switch {
+			case test.wantEvent == "" && len(recorded) != 0:
+				t.Errorf("got events %q, want none", recorded)
+			case test.wantEvent != "" && !hasEvent(recorded, test.wantEvent):
+				t.Errorf("got events %q, want %q", recorded, test.wantEvent)
+			}
+		})
+	}
+}
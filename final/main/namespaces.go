@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/kube-flux/kube-flux/apis/kubeflux/v1alpha1"
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// overrideKey is the work queue key of the EnergyPolicy overriding the policy of a namespace.
+type overrideKey string
+
+// UseNamespaces makes the controller manage the workloads of every namespace matching selector,
+// labels.Everything() for all namespaces, instead of its own namespace only. The factories of
+// the controller must watch all namespaces. It must be called before the factory is started.
+func (c *Controller) UseNamespaces(factory informers.SharedInformerFactory, selector labels.Selector) {
+	informer := factory.Core().V1().Namespaces()
+	c.namespaceLister = informer.Lister()
+	c.namespaceSelector = selector
+	c.informersSynced = append(c.informersSynced, informer.Informer().HasSynced)
+	// a namespace starting or stopping to match the selector changes the workloads of every class
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { c.enqueueAll() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNamespace, oldOK := oldObj.(*corev1.Namespace)
+			newNamespace, newOK := newObj.(*corev1.Namespace)
+			if oldOK && newOK && reflect.DeepEqual(oldNamespace.GetLabels(), newNamespace.GetLabels()) {
+				return
+			}
+			c.enqueueAll()
+		},
+		DeleteFunc: func(interface{}) { c.enqueueAll() },
+	})
+}
+
+// managedNamespaces returns the namespaces whose workloads the controller manages.
+func (c *Controller) managedNamespaces() ([]string, error) {
+	if c.namespaceLister == nil {
+		return []string{c.namespace}, nil
+	}
+	namespaces, err := c.namespaceLister.List(c.namespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		names = append(names, namespace.GetName())
+	}
+	return names, nil
+}
+
+// manages reports whether the controller manages the workloads of a namespace.
+func (c *Controller) manages(namespace string) bool {
+	if c.namespaceLister == nil {
+		return namespace == c.namespace
+	}
+	ns, err := c.namespaceLister.Get(namespace)
+	if err != nil {
+		return false
+	}
+	return c.namespaceSelector.Matches(labels.Set(ns.GetLabels()))
+}
+
+// listManagedWorkloads lists the workloads of every managed namespace from the informer caches.
+func (c *Controller) listManagedWorkloads() ([]*scaling.Workload, error) {
+	namespaces, err := c.managedNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	var workloads []*scaling.Workload
+	for _, namespace := range namespaces {
+		namespaceWorkloads, err := c.listWorkloads(namespace)
+		if err != nil {
+			return nil, err
+		}
+		workloads = append(workloads, namespaceWorkloads...)
+	}
+	return workloads, nil
+}
+
+// namespacePolicy is the policy applied to the workloads of a namespace: the policy of the
+// controller with the overrides of the namespace's EnergyPolicy.
+type namespacePolicy struct {
+	Status      policy.Status
+	Factor      policy.Factors
+	Percentages policy.Percentages
+	// Override is the namespace/name of the EnergyPolicy overriding the policy, empty without one.
+	Override string
+}
+
+// policyOf returns the policy of the workloads of a namespace, with the replica factors derived
+// from the usage of the namespace unless they are pinned. When several namespaces are managed
+// and the policy is read from an EnergyPolicy, an EnergyPolicy named like the watched one in a
+// managed namespace overrides the status and, when it sets any, the factors and percentages of
+// the policy in that namespace, and its scaling rules, see scalingRules. An invalid override is
+// ignored, its Applied condition tells why.
+func (c *Controller) policyOf(namespace string) namespacePolicy {
+	policyLock.RLock()
+	p := namespacePolicy{Status: currPolicy.Status, Factor: currPolicy.Factor, Percentages: currPolicy.Percentages}
+	policyLock.RUnlock()
+	if factors, ok := c.factorsOf(namespace); ok {
+		p.Factor = factors
+	}
+
+	o, err := c.parsedOverride(namespace)
+	if err != nil || o == nil || o.err != nil {
+		return p
+	}
+	p.Status = o.status
+	if len(o.factors) != 0 {
+		p.Factor = o.factors
+	}
+	if o.percentages != nil {
+		p.Percentages = o.percentages
+	}
+	p.Override = o.ep.GetNamespace() + "/" + o.ep.GetName()
+	return p
+}
+
+// override is an EnergyPolicy overriding the policy of a namespace, parsed once per resource version.
+type override struct {
+	ep          *v1alpha1.EnergyPolicy
+	status      policy.Status
+	factors     policy.Factors
+	percentages policy.Percentages
+	// rules are the scaling rules of the override, nil when it configures none.
+	rules *scaling.RuleSet
+	// reason and err tell why the override is invalid, err is nil when it is valid.
+	reason string
+	err    error
+}
+
+// parseOverride validates every field of an EnergyPolicy overriding the policy of a namespace.
+func parseOverride(ep *v1alpha1.EnergyPolicy) *override {
+	o := &override{ep: ep}
+	o.status, o.factors, o.percentages, o.reason, o.err = parseEnergyPolicySpec(&ep.Spec)
+	if o.err != nil {
+		return o
+	}
+	if o.rules, o.err = energyPolicyRules(&ep.Spec); o.err != nil {
+		o.reason = "InvalidRules"
+	}
+	return o
+}
+
+// parsedOverride returns the parsed EnergyPolicy overriding the policy of a namespace, nil when
+// there is none. The EnergyPolicy is parsed again when its resource version changes.
+func (c *Controller) parsedOverride(namespace string) (*override, error) {
+	ep, err := c.overrideOf(namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ep == nil {
+		delete(c.overrides, namespace)
+		return nil, nil
+	}
+	if o := c.overrides[namespace]; o != nil && o.ep.GetResourceVersion() == ep.GetResourceVersion() {
+		return o, nil
+	}
+	o := parseOverride(ep)
+	if c.overrides == nil {
+		c.overrides = make(map[string]*override)
+	}
+	c.overrides[namespace] = o
+	return o, nil
+}
+
+// overrideOf returns the EnergyPolicy overriding the policy of a namespace, nil when there is none.
+func (c *Controller) overrideOf(namespace string) (*v1alpha1.EnergyPolicy, error) {
+	if c.namespaceLister == nil || c.energyPolicyLister == nil || namespace == c.namespace {
+		return nil, nil
+	}
+	ep, err := c.energyPolicyLister.EnergyPolicies(namespace).Get(c.energyPolicyName)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return ep, err
+}
+
+// syncOverride validates the EnergyPolicy overriding the policy of a namespace, records the
+// outcome in its Applied condition and reconciles every class with it.
+func (c *Controller) syncOverride(namespace string) error {
+	defer c.enqueueAll()
+	if !c.manages(namespace) {
+		return nil
+	}
+	o, err := c.parsedOverride(namespace)
+	if err != nil || o == nil {
+		return err
+	}
+	if o.err != nil {
+		log.Println("func", "syncOverride", "Ignoring invalid EnergyPolicy", namespace+"/"+o.ep.GetName(), "err:", o.err)
+		return c.setEnergyPolicyCondition(o.ep, metav1.ConditionFalse, o.reason, o.err.Error())
+	}
+	message := fmt.Sprintf("Overrides the policy of namespace %s with status %s", namespace, o.status)
+	if o.rules != nil {
+		message += " and its scaling rules"
+	}
+	return c.setEnergyPolicyCondition(o.ep, metav1.ConditionTrue, "OverrideApplied", message)
+}
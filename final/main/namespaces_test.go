@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/apis/kubeflux/v1alpha1"
+	"github.com/kube-flux/kube-flux/client/clientset/versioned/fake"
+	listers "github.com/kube-flux/kube-flux/client/listers/kubeflux/v1alpha1"
+	"github.com/kube-flux/kube-flux/policy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// overridingPolicy returns the EnergyPolicy named default of a namespace.
+func overridingPolicy(namespace, resourceVersion string, spec v1alpha1.EnergyPolicySpec) *v1alpha1.EnergyPolicy {
+	return &v1alpha1.EnergyPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "default", ResourceVersion: resourceVersion, Generation: 1},
+		Spec:       spec,
+	}
+}
+
+// lowRule caps the Low class at maxReplicas under the Black status.
+func lowRule(maxReplicas int32, action string) []v1alpha1.ScalingRule {
+	return []v1alpha1.ScalingRule{{
+		Status:      "Black",
+		Class:       "Low",
+		MaxReplicas: &maxReplicas,
+		Bands:       []v1alpha1.UsageBand{{Replicas: 5, Action: action}},
+	}}
+}
+
+func TestNamespaceOverrides(t *testing.T) {
+	policyLock.Lock()
+	currPolicy = policy.Default()
+	policyLock.Unlock()
+
+	overrides := []*v1alpha1.EnergyPolicy{
+		overridingPolicy("shop", "1", v1alpha1.EnergyPolicySpec{Status: "Black", Rules: lowRule(2, "")}),
+		overridingPolicy("blog", "1", v1alpha1.EnergyPolicySpec{Status: "Brown", Rules: lowRule(2, "double")}),
+		overridingPolicy("wiki", "1", v1alpha1.EnergyPolicySpec{Status: "Brown"}),
+		// the watched EnergyPolicy is not an override
+		overridingPolicy("kube-flux", "1", v1alpha1.EnergyPolicySpec{Status: "Black", Rules: lowRule(1, "")}),
+	}
+	namespaceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, name := range []string{"kube-flux", "shop", "blog", "wiki"} {
+		namespaceIndexer.Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	policyIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	fluxClient := fake.NewSimpleClientset()
+	for _, ep := range overrides {
+		policyIndexer.Add(ep)
+		fluxClient.Tracker().Add(ep)
+	}
+	c := &Controller{
+		namespace:          "kube-flux",
+		namespaceLister:    corelisters.NewNamespaceLister(namespaceIndexer),
+		namespaceSelector:  labels.Everything(),
+		fluxClient:         fluxClient,
+		energyPolicyLister: listers.NewEnergyPolicyLister(policyIndexer),
+		energyPolicyName:   "default",
+		queue:              workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+
+	tests := []struct {
+		namespace    string
+		wantStatus   policy.Status
+		wantOverride string
+		// wantLow is the Low replicas of the rules under Black, clamped from 5
+		wantLow int32
+		// wantReason is the reason of the Applied condition of the override, empty without one
+		wantReason string
+	}{
+		{namespace: "shop", wantStatus: policy.Black, wantOverride: "shop/default", wantLow: 2, wantReason: "OverrideApplied"},
+		{namespace: "blog", wantStatus: policy.Green, wantLow: 5, wantReason: "InvalidRules"},
+		{namespace: "wiki", wantStatus: policy.Brown, wantOverride: "wiki/default", wantLow: 5, wantReason: "OverrideApplied"},
+		{namespace: "kube-flux", wantStatus: policy.Green, wantLow: 5},
+	}
+	for _, test := range tests {
+		t.Run(test.namespace, func(t *testing.T) {
+			p := c.policyOf(test.namespace)
+			if p.Status != test.wantStatus || p.Override != test.wantOverride {
+				t.Errorf("got status %s of %q, want %s of %q", p.Status, p.Override, test.wantStatus, test.wantOverride)
+			}
+			if got := c.scalingRules(test.namespace).Clamp(policy.Black, policy.Low, 5); got != test.wantLow {
+				t.Errorf("got %d Low replicas from the rules, want %d", got, test.wantLow)
+			}
+
+			if err := c.syncOverride(test.namespace); err != nil {
+				t.Fatal(err)
+			}
+			ep, err := fluxClient.KubefluxV1alpha1().EnergyPolicies(test.namespace).Get(context.TODO(), "default", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			condition := meta.FindStatusCondition(ep.Status.Conditions, v1alpha1.ConditionApplied)
+			switch {
+			case test.wantReason == "" && condition != nil:
+				t.Errorf("got condition %+v, want none", condition)
+			case test.wantReason != "" && (condition == nil || condition.Reason != test.wantReason):
+				t.Errorf("got condition %+v, want reason %s", condition, test.wantReason)
+			}
+		})
+	}
+
+	ep, _ := fluxClient.KubefluxV1alpha1().EnergyPolicies("shop").Get(context.TODO(), "default", metav1.GetOptions{})
+	if condition := meta.FindStatusCondition(ep.Status.Conditions, v1alpha1.ConditionApplied); !strings.Contains(condition.Message, "scaling rules") {
+		t.Errorf("got message %q, want the scaling rules mentioned", condition.Message)
+	}
+
+	// a new version of the override is parsed again
+	policyIndexer.Update(overridingPolicy("shop", "2", v1alpha1.EnergyPolicySpec{Status: "Black", Rules: lowRule(3, "")}))
+	if got := c.scalingRules("shop").Clamp(policy.Black, policy.Low, 5); got != 3 {
+		t.Errorf("got %d Low replicas from the updated rules, want 3", got)
+	}
+	policyIndexer.Delete(overridingPolicy("shop", "2", v1alpha1.EnergyPolicySpec{}))
+	if got := c.scalingRules("shop").Clamp(policy.Black, policy.Low, 5); got != 5 {
+		t.Errorf("got %d Low replicas without the override, want the default rules", got)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kube-flux/kube-flux/client/clientset/versioned"
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Values of plan -output.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// plannedChange is the replica change reconcile makes, or would make with -dry-run, to a workload.
+// The replicas of a workload targeted by a HorizontalPodAutoscaler are the maxReplicas of the autoscaler.
+type plannedChange struct {
+	Namespace string       `json:"namespace"`
+	Kind      string       `json:"kind"`
+	Name      string       `json:"name"`
+	Class     policy.Class `json:"class"`
+	Current   int32        `json:"current"`
+	Desired   int32        `json:"desired"`
+	Reason    string       `json:"reason"`
+	// Baseline is the replicas the workload had before the first energy-driven change, or the
+	// original maxReplicas of its HorizontalPodAutoscaler.
+	Baseline *int32 `json:"baseline,omitempty"`
+	// HPA is the name of the HorizontalPodAutoscaler targeting the workload.
+	HPA string `json:"hpa,omitempty"`
+	// UnmetTarget is the target availability constraints hold the workload above.
+	UnmetTarget *int32 `json:"unmetTarget,omitempty"`
+
+	workload *scaling.Workload
+	hpa      *autoscalingv1.HorizontalPodAutoscaler
+	action   string
+	// restore is set under the release status, the baseline is dropped once restored.
+	restore bool
+}
+
+// planClass computes the replicas of every workload of an importance class under the policy of
+// its namespace, the scaling rules, the scaling behavior and the availability constraints. Under the
+// release status the baseline replicas are restored instead, and released workloads are left
+// alone. The replicas of a workload targeted by a HorizontalPodAutoscaler bound the autoscaler
+// instead. Every workload is recorded in the stabilizer, call it once per reconciliation.
+func (c *Controller) planClass(class policy.Class) ([]plannedChange, error) {
+	workloads, err := c.listManagedWorkloads()
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]namespacePolicy)
+	rules := make(map[string]*scaling.RuleSet)
+
+	var changes []plannedChange
+	for _, w := range groupWorkloads(workloads)[class] {
+		p, ok := policies[w.GetNamespace()]
+		if !ok {
+			p = c.policyOf(w.GetNamespace())
+			policies[w.GetNamespace()] = p
+			rules[w.GetNamespace()] = c.scalingRules(w.GetNamespace())
+		}
+		factor, hasFactor := p.Factor[p.Status][class]
+		percentage, hasPercentage := p.Percentages.Percentage(p.Status, class)
+		status, statusName := p.Status, string(p.Status)
+		if p.Override != "" {
+			statusName += " of EnergyPolicy " + p.Override
+		}
+		action := c.actionOf(w.GetNamespace(), status, class)
+
+		change := plannedChange{
+			Namespace: w.GetNamespace(),
+			Kind:      w.GetKind(),
+			Name:      w.GetName(),
+			Class:     class,
+			Current:   w.Replicas(),
+			workload:  w,
+			action:    action,
+		}
+		baseline, hasBaseline := baselineOf(w)
+		baselineName := "baseline"
+		hpa, err := c.autoscalerOf(w)
+		if err != nil {
+			return nil, err
+		}
+		if hpa != nil {
+			// the autoscaler sets the replicas, the energy target bounds it
+			change.HPA, change.hpa, change.Current = hpa.GetName(), hpa, hpa.Spec.MaxReplicas
+			_, baseline, hasBaseline = originalBounds(hpa)
+			baselineName = "original maxReplicas"
+		}
+		if hasBaseline {
+			change.Baseline = &baseline
+		}
+		if c.isReleasing(w) || released(w) {
+			change.Desired = change.Current
+			change.Reason = "released through the release API"
+			changes = append(changes, change)
+			continue
+		}
+		if status == c.releaseStatus {
+			change.restore, change.action = true, ""
+			if !hasBaseline {
+				change.Desired = change.Current
+				change.Reason = fmt.Sprintf("no %s to restore under status %s", baselineName, statusName)
+				changes = append(changes, change)
+				continue
+			}
+			change.Reason = fmt.Sprintf("%s %d restored under status %s", baselineName, baseline, statusName)
+			var held string
+			change.Desired, held = c.stabilizer.Stabilize(w.Key(), change.Current, baseline)
+			if held != "" {
+				change.Reason += ", held by " + held
+			}
+			if err := c.holdForAvailability(&change); err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+			continue
+		}
+
+		var wanted int32
+		switch {
+		case hasPercentage:
+			// a workload without a baseline was never changed, its replicas are the baseline
+			base := change.Current
+			if hasBaseline {
+				base = baseline
+			}
+			wanted = percentage.Replicas(base, policyLimits)
+			change.Reason = fmt.Sprintf("%s of %s %d in status %s", percentage, baselineName, base, statusName)
+		case hasFactor:
+			wanted = factor
+			change.Reason = fmt.Sprintf("factor %d of status %s", factor, statusName)
+		default:
+			change.Desired = change.Current
+			change.Reason = fmt.Sprintf("no replica factor in status %s", statusName)
+			changes = append(changes, change)
+			continue
+		}
+		target := rules[w.GetNamespace()].Clamp(status, class, wanted)
+		if target != wanted {
+			change.Reason += fmt.Sprintf(", bounded to %d by the scaling rules", target)
+		}
+		if hpa != nil && target < 1 {
+			target = 1
+			change.Reason += ", bounded to 1, the lowest maxReplicas of a HorizontalPodAutoscaler"
+		}
+		allowed := allowedReplicas(change.Current, target, action)
+		if allowed != target {
+			change.Reason += fmt.Sprintf(", held by the %q action of the scaling rules", action)
+		}
+		var held string
+		change.Desired, held = c.stabilizer.Stabilize(w.Key(), change.Current, allowed)
+		if held != "" {
+			change.Reason += ", held by " + held
+		}
+		if err := c.holdForAvailability(&change); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Plan evaluates the usage once and returns the replica changes of every importance class,
+// without changing any workload. The informer caches must be synced.
+func (c *Controller) Plan() ([]plannedChange, error) {
+	if err := c.evaluate(); err != nil {
+		return nil, err
+	}
+	var changes []plannedChange
+	for _, class := range policy.Classes {
+		classChanges, err := c.planClass(class)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, classChanges...)
+	}
+	return changes, nil
+}
+
+// printPlan writes the changes as a table or as JSON.
+func printPlan(w io.Writer, changes []plannedChange, output string) error {
+	if output == outputJSON {
+		if changes == nil {
+			changes = []plannedChange{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(changes)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKLOAD\tCLASS\tCURRENT\tDESIRED\tREASON")
+	for _, change := range changes {
+		marker := " "
+		if change.Desired != change.Current {
+			marker = "*"
+		}
+		workload := strings.ToLower(change.Kind) + "/" + change.Name
+		if change.HPA != "" {
+			workload += " (hpa/" + change.HPA + ")"
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\t%d\t%d\t%s\n", marker, workload, change.Class, change.Current, change.Desired, change.Reason)
+	}
+	return tw.Flush()
+}
+
+// runPlan syncs the informers, reads the policy once from its source and prints the changes
+// the controller would make. Nothing is written to the cluster.
+func runPlan(c *Controller, fluxClient versioned.Interface, energyPolicy string, w io.Writer, output string, stopCh <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stopCh, c.informersSynced...) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	switch {
+	case fluxClient != nil:
+		ep, err := fluxClient.KubefluxV1alpha1().EnergyPolicies(c.namespace).Get(context.TODO(), energyPolicy, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get EnergyPolicy %s: %v", energyPolicy, err)
+		}
+		if _, err := c.applyEnergyPolicy(ep); err != nil {
+			return fmt.Errorf("invalid EnergyPolicy %s: %v", energyPolicy, err)
+		}
+	case c.source != nil:
+		p, err := c.source.Get(context.TODO())
+		if err != nil {
+			return fmt.Errorf("failed to read the policy from %s: %v", c.source, err)
+		}
+		if err := p.Validate(policyLimits); err != nil {
+			return err
+		}
+		c.ApplyPolicy(p)
+	}
+
+	changes, err := c.Plan()
+	if err != nil {
+		return err
+	}
+	return printPlan(w, changes, output)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPlanClass(t *testing.T) {
+	lowMax := int32(2)
+	lowCap, err := scaling.Compile([]scaling.Rule{{Status: "Green", Class: "Low", MaxReplicas: &lowMax}}, policy.DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := policy.Percentages{policy.Green: {policy.Low: {Percent: 50}}}
+
+	tests := []struct {
+		name          string
+		workload      *unstructured.Unstructured
+		factors       policy.Factors
+		percentages   policy.Percentages
+		rules         *scaling.RuleSet
+		releaseStatus policy.Status
+		wantDesired   int32
+		wantReason    string
+		wantUnmet     bool
+	}{
+		{
+			name:        "factor",
+			workload:    workload(deployments, "web", policy.Low, 4, nil),
+			factors:     policy.Factors{policy.Green: {policy.Low: 6}},
+			wantDesired: 6,
+			wantReason:  "factor 6 of status Green",
+		},
+		{
+			name:        "statefulset",
+			workload:    workload(statefulSets, "db", policy.Low, 4, nil),
+			factors:     policy.Factors{policy.Green: {policy.Low: 2}},
+			wantDesired: 2,
+			wantReason:  "factor 2 of status Green",
+		},
+		{
+			name:        "no factor",
+			workload:    workload(deployments, "web", policy.Low, 4, nil),
+			factors:     policy.Factors{policy.Green: {policy.High: 6}},
+			wantDesired: 4,
+			wantReason:  "no replica factor in status Green",
+		},
+		{
+			name:        "percentage of the current replicas",
+			workload:    workload(deployments, "web", policy.Low, 5, nil),
+			percentages: half,
+			wantDesired: 3,
+			wantReason:  "50% of baseline 5 in status Green",
+		},
+		{
+			name:        "percentage of the baseline",
+			workload:    workload(deployments, "web", policy.Low, 2, map[string]string{baselineAnnotation: "8"}),
+			percentages: half,
+			wantDesired: 4,
+			wantReason:  "50% of baseline 8 in status Green",
+		},
+		{
+			name:        "percentage before factor",
+			workload:    workload(deployments, "web", policy.Low, 4, nil),
+			factors:     policy.Factors{policy.Green: {policy.Low: 9}},
+			percentages: half,
+			wantDesired: 2,
+			wantReason:  "50% of baseline 4",
+		},
+		{
+			name:        "clamped by the rules",
+			workload:    workload(deployments, "web", policy.Low, 4, nil),
+			factors:     policy.Factors{policy.Green: {policy.Low: 6}},
+			rules:       lowCap,
+			wantDesired: 2,
+			wantReason:  "bounded to 2 by the scaling rules",
+		},
+		{
+			name:        "held by min-replicas",
+			workload:    workload(deployments, "web", policy.Low, 4, map[string]string{minReplicasAnnotation: "3"}),
+			factors:     policy.Factors{policy.Green: {policy.Low: 1}},
+			wantDesired: 3,
+			wantReason:  "held at 3 instead of 1",
+			wantUnmet:   true,
+		},
+		{
+			name:          "baseline restored",
+			workload:      workload(deployments, "web", policy.Low, 2, map[string]string{baselineAnnotation: "6"}),
+			factors:       policy.Factors{policy.Green: {policy.Low: 1}},
+			releaseStatus: policy.Green,
+			wantDesired:   6,
+			wantReason:    "baseline 6 restored under status Green",
+		},
+		{
+			name:          "no baseline to restore",
+			workload:      workload(deployments, "web", policy.Low, 2, nil),
+			factors:       policy.Factors{policy.Green: {policy.Low: 1}},
+			releaseStatus: policy.Green,
+			wantDesired:   2,
+			wantReason:    "no baseline to restore under status Green",
+		},
+		{
+			name:        "released",
+			workload:    workload(deployments, "web", policy.Low, 2, map[string]string{releasedAnnotation: "true"}),
+			factors:     policy.Factors{policy.Green: {policy.Low: 1}},
+			wantDesired: 2,
+			wantReason:  "released through the release API",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setPolicy(&policy.Policy{APIVersion: policy.APIVersion, Status: policy.Green, Factor: test.factors, Percentages: test.percentages})
+			c, _ := newTestController(t, nil, test.workload)
+			c.fileRules = test.rules
+			c.releaseStatus = test.releaseStatus
+
+			changes, err := c.planClass(policy.Low)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != 1 {
+				t.Fatalf("got %d changes, want 1", len(changes))
+			}
+			change := changes[0]
+			if change.Name != test.workload.GetName() || change.Kind != test.workload.GetKind() {
+				t.Errorf("got %s %s, want %s %s", change.Kind, change.Name, test.workload.GetKind(), test.workload.GetName())
+			}
+			if change.Desired != test.wantDesired || !strings.Contains(change.Reason, test.wantReason) {
+				t.Errorf("got %d replicas (%s), want %d (%s)", change.Desired, change.Reason, test.wantDesired, test.wantReason)
+			}
+			if unmet := change.UnmetTarget != nil; unmet != test.wantUnmet {
+				t.Errorf("got unmet target %t, want %t", unmet, test.wantUnmet)
+			}
+		})
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	changes := []plannedChange{
+		{Namespace: "shop", Kind: "Deployment", Name: "web", Class: policy.Low, Current: 4, Desired: 2, Reason: "factor 2 of status Black"},
+		{Namespace: "shop", Kind: "StatefulSet", Name: "db", Class: policy.Low, Current: 3, Desired: 3, Reason: "no replica factor in status Black", HPA: "db"},
+	}
+	tests := []struct {
+		name    string
+		changes []plannedChange
+		output  string
+		want    string
+	}{
+		{
+			name:    "table",
+			changes: changes,
+			want: "WORKLOAD                   CLASS  CURRENT  DESIRED  REASON\n" +
+				"* deployment/web           Low    4        2        factor 2 of status Black\n" +
+				"  statefulset/db (hpa/db)  Low    3        3        no replica factor in status Black\n",
+		},
+		{name: "empty table", want: "WORKLOAD  CLASS  CURRENT  DESIRED  REASON\n"},
+		{name: "empty json", output: outputJSON, want: "[]\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printPlan(&out, test.changes, test.output); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), test.want)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	if err := printPlan(&out, changes, outputJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0]["desired"] != 2.0 || decoded[1]["hpa"] != "db" || decoded[0]["hpa"] != nil {
+		t.Errorf("got %s", out.String())
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+
+	"github.com/kube-flux/kube-flux/apis/kubeflux/v1alpha1"
+	"github.com/kube-flux/kube-flux/scaling"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Usage bands of the High class of the default rules, in nanocores.
+const (
+	defaultScaleDownCPU = 1000000
+	defaultScaleUpCPU   = 100
+)
+
+// defaultRules are used when neither -scaling-rules nor the EnergyPolicy configure any.
+var defaultRules = scaling.Default(defaultScaleDownCPU, defaultScaleUpCPU)
+
+// scalingRules returns the rules applied to the workloads of a namespace: those of the EnergyPolicy
+// overriding its policy, then those of the watched EnergyPolicy, of the -scaling-rules file, or the
+// default rules.
+func (c *Controller) scalingRules(namespace string) *scaling.RuleSet {
+	if o, err := c.parsedOverride(namespace); err == nil && o != nil && o.err == nil && o.rules != nil {
+		return o.rules
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.policyRules != nil {
+		return c.policyRules
+	}
+	if c.fileRules != nil {
+		return c.fileRules
+	}
+	return defaultRules
+}
+
+// WatchScalingRules reloads the scaling rules of a YAML or JSON file whenever it changes.
+// The file is loaded once before WatchScalingRules returns, an invalid file is an error.
+func (c *Controller) WatchScalingRules(path string, stopCh <-chan struct{}) error {
+	watcher := &rulesFile{path: path, controller: c}
+	if err := watcher.load(); err != nil {
+		return err
+	}
+	go wait.Until(func() {
+		if err := watcher.load(); err != nil {
+			log.Println("func", "rulesFile.Run", "Failed to reload", path, "keeping the previous rules", "err:", err)
+		}
+	}, filePollPeriod, stopCh)
+	return nil
+}
+
+// rulesFile is a scaling rules file checked for changes every filePollPeriod.
+type rulesFile struct {
+	path       string
+	controller *Controller
+	// last is the content last loaded, only used by the polling goroutine.
+	last []byte
+}
+
+// load replaces the rules of the controller if the content of the file changed since the last load.
+func (f *rulesFile) load() error {
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	if f.last != nil && bytes.Equal(content, f.last) {
+		return nil
+	}
+	rules, err := scaling.Parse(content, policyLimits)
+	if err != nil {
+		return err
+	}
+	f.last = content
+	log.Println("func", "rulesFile.load", "Loaded scaling rules from", f.path)
+
+	c := f.controller
+	c.mu.Lock()
+	c.fileRules = rules
+	// the next metrics resync evaluates the new rules
+	c.mu.Unlock()
+	return nil
+}
+
+// convertRules converts the rules of an EnergyPolicy spec into scaling rules.
+func convertRules(spec []v1alpha1.ScalingRule) []scaling.Rule {
+	rules := make([]scaling.Rule, 0, len(spec))
+	for _, r := range spec {
+		rule := scaling.Rule{
+			Status:      r.Status,
+			Class:       r.Class,
+			UsageClass:  r.UsageClass,
+			MinReplicas: r.MinReplicas,
+			MaxReplicas: r.MaxReplicas,
+		}
+		for _, band := range r.Bands {
+			rule.Bands = append(rule.Bands, scaling.Band{
+				CPUAbove:    band.CPUAbove,
+				CPUBelow:    band.CPUBelow,
+				MemoryAbove: band.MemoryAbove,
+				MemoryBelow: band.MemoryBelow,
+				Replicas:    band.Replicas,
+				Action:      band.Action,
+			})
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// energyPolicyRules compiles the rules of an EnergyPolicy spec. The deprecated thresholds are
+// applied to the default rules when the spec has no rules; nil means the spec configures none.
+func energyPolicyRules(spec *v1alpha1.EnergyPolicySpec) (*scaling.RuleSet, error) {
+	if len(spec.Rules) != 0 {
+		return scaling.Compile(convertRules(spec.Rules), policyLimits)
+	}
+	if spec.Thresholds != nil {
+		return scaling.Default(spec.Thresholds.ScaleDownCPU, spec.Thresholds.ScaleUpCPU), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kube-flux/kube-flux/policy"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/yaml"
+)
+
+// Values of -policy-source besides a Zeus URL and a file.
+const (
+	// sourceEmbedded keeps the policy in memory, changed through PUT /policy on the controller.
+	sourceEmbedded = "embedded"
+	// sourceCRD reads the policy from the EnergyPolicy named by -energy-policy.
+	sourceCRD = "crd"
+)
+
+const (
+	// zeusRetryPeriod is how long the controller waits before watching Zeus again after the stream ended.
+	zeusRetryPeriod = 5 * time.Second
+	// filePollPeriod is how often a policy file is checked for changes.
+	filePollPeriod = 5 * time.Second
+	// maxEventSize bounds the size of one Server-Sent Event read from Zeus.
+	maxEventSize = 1 << 20
+)
+
+// errReadOnlyPolicy is returned by SetPolicy when the policy is owned by Zeus or a file.
+var errReadOnlyPolicy = errors.New("the policy is read from an external source")
+
+// errDryRun is returned by SetPolicy when changing the policy would write to the cluster under -dry-run.
+var errDryRun = errors.New("the EnergyPolicy isn't changed with -dry-run")
+
+// PolicySource is an external owner of the policy the controller subscribes to.
+type PolicySource interface {
+	// Run calls apply with every policy received from the source until stopCh is closed.
+	Run(stopCh <-chan struct{}, apply func(*policy.Policy))
+	// Get reads the current policy once.
+	Get(ctx context.Context) (*policy.Policy, error)
+	// String describes the source in logs and errors.
+	String() string
+}
+
+// UsePolicySource makes an external source the owner of the policy; PUT /policy is then rejected.
+func (c *Controller) UsePolicySource(source PolicySource) {
+	c.source = source
+}
+
+// ApplyPolicy replaces the status and, when p carries any, the replica factors of the policy.
+// Invalid policies are logged and ignored.
+func (c *Controller) ApplyPolicy(p *policy.Policy) {
+	if err := p.Validate(policyLimits); err != nil {
+		log.Println("func", "ApplyPolicy", "Ignoring invalid policy", "err:", err)
+		return
+	}
+	c.applyPolicy(p, "")
+}
+
+// applyPolicy applies a valid policy if the If-Match header value ifMatch matches the current revision.
+func (c *Controller) applyPolicy(p *policy.Policy, ifMatch string) error {
+	policyLock.Lock()
+	if !policy.IfMatch(ifMatch, currPolicy.Revision) {
+		policyLock.Unlock()
+		return policy.ErrPreconditionFailed
+	}
+	statusChanged := currPolicy.Status != p.Status
+	factorsChanged := len(p.Factor) != 0 && !reflect.DeepEqual(currPolicy.Factor, p.Factor)
+	// a policy without percentages keeps the current ones, {} removes them
+	percentagesChanged := p.Percentages != nil && !reflect.DeepEqual(currPolicy.Percentages, p.Percentages)
+	if !statusChanged && !factorsChanged && !percentagesChanged {
+		policyLock.Unlock()
+		log.Println("func", "ApplyPolicy", "Same status")
+		return nil
+	}
+	currPolicy.Status = p.Status
+	if factorsChanged {
+		currPolicy.Factor = p.Factor.DeepCopy()
+	}
+	if percentagesChanged {
+		currPolicy.Percentages = p.Percentages.DeepCopy()
+	}
+	currPolicy.UpdatedAt = p.UpdatedAt
+	if currPolicy.UpdatedAt.IsZero() {
+		currPolicy.UpdatedAt = time.Now()
+	}
+	publishPolicy()
+	policyLock.Unlock()
+
+	if len(p.Factor) != 0 {
+		c.mu.Lock()
+		c.pinnedFactor = p.Factor.DeepCopy()
+		c.mu.Unlock()
+	}
+	log.Println("func", "ApplyPolicy", "Applied policy", p.Status)
+	c.PolicyChanged()
+	return nil
+}
+
+// newPolicySource creates the source of a -policy-source value: an http(s) URL of Zeus,
+// or a JSON or YAML file given as file:<path>. The token of tokenFile, if any, authenticates the
+// requests to Zeus.
+func newPolicySource(value, tokenFile string) (PolicySource, error) {
+	if strings.HasPrefix(value, "file:") {
+		path := strings.TrimPrefix(strings.TrimPrefix(value, "file:"), "//")
+		if path == "" {
+			return nil, fmt.Errorf("missing path in policy source %q", value)
+		}
+		if tokenFile != "" {
+			return nil, fmt.Errorf("a token file only applies to a Zeus policy source, not %q", value)
+		}
+		return &fileSource{path: path}, nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("unknown policy source %q, expected %s, %s, a Zeus URL or file:<path>", value, sourceEmbedded, sourceCRD)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/policy"
+	}
+	if tokenFile != "" {
+		// fail at startup rather than at every request
+		if _, err := readToken(tokenFile); err != nil {
+			return nil, err
+		}
+	}
+	return &zeusSource{url: u, client: &http.Client{}, tokenFile: tokenFile}, nil
+}
+
+// readToken reads a bearer token file, ignoring surrounding whitespace.
+func readToken(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// zeusSource watches the policy of Zeus over Server-Sent Events, resuming after the last event
+// it received when the stream is interrupted.
+type zeusSource struct {
+	url    *url.URL
+	client *http.Client
+	// tokenFile holds the bearer token of the requests, read at every request so that a rotated
+	// token is picked up; no token is sent when empty.
+	tokenFile string
+	// lastEventID is the resource version of the last policy received, only used by Run's goroutine.
+	lastEventID string
+}
+
+func (s *zeusSource) String() string {
+	return "Zeus at " + s.url.String()
+}
+
+// Run watches Zeus until stopCh is closed, watching again zeusRetryPeriod after every disconnection.
+func (s *zeusSource) Run(stopCh <-chan struct{}, apply func(*policy.Policy)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	wait.JitterUntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.watch(ctx, apply); err != nil && ctx.Err() == nil {
+			log.Println("func", "zeusSource.Run", "Watch of", s, "ended", "err:", err)
+		}
+	}, zeusRetryPeriod, 0.5, true)
+}
+
+// newRequest creates a GET request of u, authenticated with the token of the source.
+func (s *zeusSource) newRequest(ctx context.Context, u *url.URL) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.tokenFile != "" {
+		token, err := readToken(s.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// Get reads the current policy of Zeus with a plain GET.
+func (s *zeusSource) Get(ctx context.Context) (*policy.Policy, error) {
+	req, err := s.newRequest(ctx, s.url)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Decode(body)
+}
+
+// watch reads one watch stream of Zeus and applies every policy event.
+func (s *zeusSource) watch(ctx context.Context, apply func(*policy.Policy)) error {
+	u := *s.url
+	query := u.Query()
+	query.Set("watch", "true")
+	u.RawQuery = query.Encode()
+	req, err := s.newRequest(ctx, &u)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if s.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		// too far behind to resume, the next watch starts with the current policy
+		gone := s.lastEventID
+		s.lastEventID = ""
+		return fmt.Errorf("resource version %s is gone", gone)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	log.Println("func", "zeusSource.watch", "Watching", s)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 4096), maxEventSize)
+	var id, event string
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 && (event == "" || event == "policy") {
+				p, err := policy.Decode(data.Bytes())
+				if err != nil {
+					return fmt.Errorf("failed to decode policy event %s: %v", id, err)
+				}
+				apply(p)
+				if id != "" {
+					s.lastEventID = id
+				}
+			}
+			id, event = "", ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// comment, e.g. a heartbeat
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("stream closed by Zeus")
+}
+
+// fileSource reads the policy from a JSON or YAML file, applying it again whenever the file changes.
+type fileSource struct {
+	path string
+	// last is the content last applied, only used by Run's goroutine.
+	last []byte
+}
+
+func (s *fileSource) String() string {
+	return "file " + s.path
+}
+
+// Run checks the file every filePollPeriod until stopCh is closed.
+func (s *fileSource) Run(stopCh <-chan struct{}, apply func(*policy.Policy)) {
+	wait.Until(func() {
+		if err := s.load(apply); err != nil {
+			log.Println("func", "fileSource.Run", "Failed to load", s, "err:", err)
+		}
+	}, filePollPeriod, stopCh)
+}
+
+// load applies the policy of the file if its content changed since the last load.
+func (s *fileSource) load(apply func(*policy.Policy)) error {
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	if s.last != nil && bytes.Equal(content, s.last) {
+		return nil
+	}
+	p, err := decodePolicyFile(content)
+	if err != nil {
+		return err
+	}
+	s.last = content
+	log.Println("func", "fileSource.load", "Loaded policy", p.Status, "from", s)
+	apply(p)
+	return nil
+}
+
+// Get reads the policy of the file.
+func (s *fileSource) Get(_ context.Context) (*policy.Policy, error) {
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return decodePolicyFile(content)
+}
+
+// decodePolicyFile decodes a policy in the Policy API format, written in JSON or YAML.
+func decodePolicyFile(content []byte) (*policy.Policy, error) {
+	data, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Decode(data)
+}
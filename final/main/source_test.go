@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/policy"
+)
+
+func TestNewPolicySource(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value     string
+		tokenFile string
+		want      string
+		wantErr   bool
+	}{
+		{value: "file:/etc/kube-flux/policy.yaml", want: "file /etc/kube-flux/policy.yaml"},
+		{value: "file:///etc/kube-flux/policy.yaml", want: "file /etc/kube-flux/policy.yaml"},
+		{value: "http://zeus:9999", want: "Zeus at http://zeus:9999/policy"},
+		{value: "https://zeus/", tokenFile: tokenFile, want: "Zeus at https://zeus/policy"},
+		{value: "http://zeus:9999/v2/policy", want: "Zeus at http://zeus:9999/v2/policy"},
+		{value: "file:", wantErr: true},
+		{value: "file:policy.yaml", tokenFile: tokenFile, wantErr: true},
+		{value: "ftp://zeus", wantErr: true},
+		{value: "zeus:9999", wantErr: true},
+		{value: "http://zeus", tokenFile: filepath.Join(t.TempDir(), "missing"), wantErr: true},
+	}
+	for _, test := range tests {
+		source, err := newPolicySource(test.value, test.tokenFile)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.value, err, test.wantErr)
+			continue
+		}
+		if err == nil && source.String() != test.want {
+			t.Errorf("%s: got %s, want %s", test.value, source, test.want)
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	source := &fileSource{path: path}
+	var applied []policy.Status
+	apply := func(p *policy.Policy) { applied = append(applied, p.Status) }
+
+	steps := []struct {
+		content string
+		want    []policy.Status
+		wantErr bool
+	}{
+		{content: "apiVersion: v1\nstatus: Brown\n", want: []policy.Status{policy.Brown}},
+		// an unchanged file isn't applied again
+		{content: "apiVersion: v1\nstatus: Brown\n", want: []policy.Status{policy.Brown}},
+		{content: `{"apiVersion": "v1", "status": "Black"}`, want: []policy.Status{policy.Brown, policy.Black}},
+		{content: "status: [", want: []policy.Status{policy.Brown, policy.Black}, wantErr: true},
+	}
+	for i, step := range steps {
+		if err := ioutil.WriteFile(path, []byte(step.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := source.load(apply)
+		if (err != nil) != step.wantErr {
+			t.Errorf("step %d: got error %v, want error %t", i, err, step.wantErr)
+		}
+		if fmt.Sprint(applied) != fmt.Sprint(step.want) {
+			t.Errorf("step %d: got applied %v, want %v", i, applied, step.want)
+		}
+	}
+
+	ioutil.WriteFile(path, []byte("apiVersion: v1\nstatus: Green\n"), 0600)
+	if p, err := source.Get(context.TODO()); err != nil || p.Status != policy.Green {
+		t.Errorf("got %v, %v, want status Green", p, err)
+	}
+}
+
+func TestZeusSource(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	ioutil.WriteFile(tokenFile, []byte("secret"), 0600)
+	var lastEventIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Query().Get("watch") != "true" {
+			fmt.Fprint(w, `{"apiVersion": "v1", "status": "Brown"}`)
+			return
+		}
+		lastEventIDs = append(lastEventIDs, req.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": heartbeat\n\n")
+		fmt.Fprint(w, "id: 4\nevent: policy\ndata: {\"apiVersion\": \"v1\",\n")
+		fmt.Fprint(w, "data:  \"status\": \"Black\"}\n\n")
+		fmt.Fprint(w, "id: 5\nevent: other\ndata: {}\n\n")
+		fmt.Fprint(w, "id: 6\ndata: {\"apiVersion\": \"v1\", \"status\": \"Green\"}\n\n")
+	}))
+	defer server.Close()
+
+	source, err := newPolicySource(server.URL, tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := source.Get(context.TODO())
+	if err != nil || p.Status != policy.Brown {
+		t.Fatalf("got %v, %v, want status Brown", p, err)
+	}
+
+	zeus := source.(*zeusSource)
+	var applied []policy.Status
+	for i := 0; i < 2; i++ {
+		if err := zeus.watch(context.TODO(), func(p *policy.Policy) { applied = append(applied, p.Status) }); err == nil {
+			t.Error("got no error at the end of the stream")
+		}
+	}
+	if want := []policy.Status{policy.Black, policy.Green, policy.Black, policy.Green}; fmt.Sprint(applied) != fmt.Sprint(want) {
+		t.Errorf("got applied %v, want %v", applied, want)
+	}
+	// the second watch resumes after the last policy event
+	if want := []string{"", "6"}; fmt.Sprint(lastEventIDs) != fmt.Sprint(want) {
+		t.Errorf("got Last-Event-IDs %q, want %q", lastEventIDs, want)
+	}
+
+	unauthenticated := &zeusSource{url: zeus.url, client: http.DefaultClient}
+	if _, err := unauthenticated.Get(context.TODO()); err == nil {
+		t.Error("got no error without a token")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventComponent is the source of the Events recorded by the controller.
+const eventComponent = "kube-flux"
+
+// Reasons of the Events recorded on scaled workloads.
+const (
+	reasonScaled      = "Scaled"
+	reasonScaleFailed = "ScaleFailed"
+)
+
+var (
+	// scaleOperations counts the replica changes by result, served on /metrics.
+	scaleOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kubeflux",
+		Name:      "scale_operations_total",
+		Help:      "Replica changes of workloads by resource, importance class and result: success, conflict, forbidden, not_found or error.",
+	}, []string{"namespace", "resource", "class", "result"})
+	// suspensions counts the suspensions and resumptions of batch workloads by result.
+	suspensions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kubeflux",
+		Name:      "suspensions_total",
+		Help:      "Suspensions, pauses and resumptions of CronJobs and Jobs by kind, action and result.",
+	}, []string{"namespace", "kind", "action", "result"})
+	// unmetTargets counts the workloads availability constraints hold above their target.
+	unmetTargets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kubeflux",
+		Name:      "unmet_targets",
+		Help:      "Workloads of an importance class held above their energy target by a PodDisruptionBudget or their minimum replicas at the last reconciliation.",
+	}, []string{"namespace", "class"})
+	// reconcileErrors counts the failed reconciliations by work queue key.
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kubeflux",
+		Name:      "reconcile_errors_total",
+		Help:      "Failed reconciliations by work queue key, retried with back-off.",
+	}, []string{"key"})
+	// usageRefreshErrors counts the failed reads of pod usage.
+	usageRefreshErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "kubeflux",
+		Name:      "usage_refresh_errors_total",
+		Help:      "Failed reads of the pod usage from the metrics source.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(scaleOperations, suspensions, unmetTargets, reconcileErrors, usageRefreshErrors)
+}
+
+// scaleResult classifies the error of a replica change for scaleOperations.
+func scaleResult(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case apierrors.IsConflict(err):
+		return "conflict"
+	case apierrors.IsForbidden(err):
+		return "forbidden"
+	case apierrors.IsNotFound(err):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
+
+// newEventRecorder records Events through the API server.
+func newEventRecorder(clientSet kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+}
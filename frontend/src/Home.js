@@ -2,26 +2,30 @@ import React, { useState, useEffect } from 'react';
 import './App.css';
 import NavBar from './components/NavBar'
 import Table from './components/Table'
+import watchPolicy, { authHeaders, policyURL } from './watchPolicy'
 import { Button, Card, Divider, Icon, Header } from 'semantic-ui-react'
 
 export default function Home() {
     
     const [currStatus, setStatus] = useState()
+    const [currPolicy, setPolicy] = useState()
 
     useEffect(() => {
-        const headers = { 'Content-Type': 'application/json' }
-        fetch('http://localhost:8888/policy', headers)
-            .then(response => response.json())
-            .then(data => setStatus(data.Status.toLowerCase()))
-    })
+        // the first event is the current policy, later ones are pushed on every change
+        return watchPolicy(policy => {
+            setPolicy(policy)
+            setStatus(policy.Status.toLowerCase())
+        })
+    }, [])
 
     function handleOnClick(status) {
-        const data = { "Status": status, "Factor": "null" }
+        const data = { "APIVersion": "v1", "Status": status }
         if (status.toLowerCase() !== currStatus) {
-            fetch('http://localhost:8888/policy', {
+            fetch(policyURL, {
                 method: 'PUT',
                 headers: {
                     'Content-Type': 'application/json',
+                    ...authHeaders(),
                 },
                 body: JSON.stringify(data)
             })
@@ -49,9 +53,9 @@ export default function Home() {
                 </Divider>
                 <Button basic color='green' size='huge' content='Green' onClick={() => handleOnClick('Green')}/>
                 {' '}
-                <Button basic color='yellow' size='huge' content='Yellow' onClick={() => handleOnClick('Yellow')} />
+                <Button basic color='brown' size='huge' content='Brown' onClick={() => handleOnClick('Brown')} />
                 {' '}
-                <Button basic color='red' size='huge' content='Red' onClick={() => handleOnClick('Red')}/>
+                <Button basic color='black' size='huge' content='Black' onClick={() => handleOnClick('Black')}/>
             </div>
             
             <div>
@@ -63,7 +67,7 @@ export default function Home() {
                     </Header>
                 </Divider>
                 
-                <Table currentStatus={currStatus}/>
+                <Table policy={currPolicy}/>
             </div>   
         </div>
   );
@@ -1,4 +1,4 @@
-import React, { useState, useEffect, useRef } from 'react';
+import React from 'react';
 import { makeStyles } from '@material-ui/core/styles';
 import Table from '@material-ui/core/Table';
 import TableBody from '@material-ui/core/TableBody';
@@ -18,52 +18,13 @@ function createData(name, num) {
   return { name, num };
 }
 
-export default function BasicTable({ currentStatus }) {
+// BasicTable lists the replica factors of the classes in the status of the policy, which Home
+// keeps up to date from the policy watch.
+export default function BasicTable({ policy }) {
   const classes = useStyles();
-  const [rows, setRows] = useState([])
 
-  function capitalize(word) {
-    return word.charAt(0).toUpperCase() + word.slice(1);
-  }
-
-  function useInterval(callback, delay) {
-    const savedCallback = useRef();
-  
-    // Remember the latest callback.
-    useEffect(() => {
-      savedCallback.current = callback;
-    }, [callback]);
-  
-    // Set up the interval.
-    useEffect(() => {
-      function tick() {
-        savedCallback.current();
-      }
-      if (delay !== null) {
-        let id = setInterval(tick, delay);
-        return () => clearInterval(id);
-      }
-    }, [delay]);
-  }
-
-  useInterval(() => {
-      const headers = { 'Content-Type': 'application/json' }
-      fetch('http://localhost:8888/policy', headers)
-        .then(response => response.json())
-        .then(data => {
-            let currStatusCap = capitalize(currentStatus)
-            console.log(currStatusCap)
-
-            let result = data.Factor[currStatusCap]
-            let rowsArr = []
-
-            for (const property in result) {
-                rowsArr.push(createData(property, result[property]))
-            }
-
-            setRows(rowsArr)
-        })
-  }, 1000)
+  const factors = (policy && policy.Factor && policy.Factor[policy.Status]) || {}
+  const rows = Object.keys(factors).map(name => createData(name, factors[name]))
 
   return (
     <TableContainer component={Paper}>
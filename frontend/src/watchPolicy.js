@@ -0,0 +1,89 @@
+// policyURL is the policy API of the controller
+export const policyURL = 'http://localhost:8888/policy'
+
+// token is the bearer token of the policy API, when it requires authentication
+export const token = process.env.REACT_APP_POLICY_TOKEN
+
+// authHeaders returns the Authorization header of the token, if any
+export function authHeaders() {
+    return token ? { 'Authorization': 'Bearer ' + token } : {}
+}
+
+// retryDelay is how long to wait before watching again after the stream ended
+const retryDelay = 5000
+
+// watchPolicy calls onPolicy with the current policy, then with every change, until the returned
+// function is called. EventSource can't set headers, so the Server-Sent Events are read with fetch
+// to pass the token in the Authorization header instead of the URL.
+export default function watchPolicy(onPolicy) {
+    const controller = new AbortController()
+    let lastEventID = ''
+    let timer
+
+    async function watch() {
+        const headers = { 'Accept': 'text/event-stream', ...authHeaders() }
+        if (lastEventID) {
+            headers['Last-Event-ID'] = lastEventID
+        }
+        const response = await fetch(policyURL + '?watch=true', { headers, signal: controller.signal })
+        if (response.status === 410) {
+            // too far behind to resume, the next watch starts with the current policy
+            lastEventID = ''
+        }
+        if (!response.ok) {
+            throw new Error('unexpected status ' + response.status)
+        }
+
+        const reader = response.body.getReader()
+        const decoder = new TextDecoder()
+        let buffer = ''
+        for (;;) {
+            const { value, done } = await reader.read()
+            if (done) {
+                return
+            }
+            buffer += decoder.decode(value, { stream: true })
+            let end
+            while ((end = buffer.indexOf('\n\n')) >= 0) {
+                dispatch(buffer.slice(0, end))
+                buffer = buffer.slice(end + 2)
+            }
+        }
+    }
+
+    function dispatch(block) {
+        let id = '', event = '', data = []
+        for (const line of block.split('\n')) {
+            if (line.startsWith('id:')) {
+                id = line.slice(3).trim()
+            } else if (line.startsWith('event:')) {
+                event = line.slice(6).trim()
+            } else if (line.startsWith('data:')) {
+                data.push(line.slice(5).replace(/^ /, ''))
+            }
+        }
+        if (data.length === 0 || (event !== '' && event !== 'policy')) {
+            return
+        }
+        onPolicy(JSON.parse(data.join('\n')))
+        if (id) {
+            lastEventID = id
+        }
+    }
+
+    function run() {
+        watch()
+            .catch(() => {})
+            .finally(() => {
+                if (!controller.signal.aborted) {
+                    timer = setTimeout(run, retryDelay)
+                }
+            })
+    }
+
+    run()
+    return () => {
+        controller.abort()
+        clearTimeout(timer)
+    }
+}
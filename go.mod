@@ -1,10 +1,13 @@
 module github.com/kube-flux/kube-flux
 
-go 1.15
+go 1.16
 
 require (
-	golang.org/x/sys v0.0.0-20201006155630-ac719f4daadf // indirect
-	k8s.io/api v0.19.0
-	k8s.io/apimachinery v0.19.0
-	k8s.io/client-go v0.19.0
+	github.com/boltdb/bolt v1.3.1
+	github.com/prometheus/client_golang v1.7.1
+	k8s.io/api v0.21.14
+	k8s.io/apimachinery v0.21.14
+	k8s.io/client-go v0.21.14
+	k8s.io/metrics v0.21.14
+	sigs.k8s.io/yaml v1.2.0
 )
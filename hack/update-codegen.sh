@@ -0,0 +1,29 @@
+#!/usr/bin/env bash
+
+# Regenerates the deepcopy functions, clientset, listers and informers of the kube-flux.io API group.
+# The generators of k8s.io/code-generator v0.21.14 are expected on the PATH.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+MODULE=github.com/kube-flux/kube-flux
+APIS=${MODULE}/apis/kubeflux/v1alpha1
+OUTPUT=${MODULE}/client
+ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+TMP=$(mktemp -d)
+trap 'rm -rf "${TMP}"' EXIT
+
+HEADER=${ROOT}/hack/boilerplate.go.txt
+
+deepcopy-gen --input-dirs "${APIS}" -O zz_generated.deepcopy \
+  --go-header-file "${HEADER}" --output-base "${TMP}"
+client-gen --clientset-name versioned --input-base "" --input "${APIS}" \
+  --output-package "${OUTPUT}/clientset" --go-header-file "${HEADER}" --output-base "${TMP}"
+lister-gen --input-dirs "${APIS}" --output-package "${OUTPUT}/listers" \
+  --go-header-file "${HEADER}" --output-base "${TMP}"
+informer-gen --input-dirs "${APIS}" --versioned-clientset-package "${OUTPUT}/clientset/versioned" \
+  --listers-package "${OUTPUT}/listers" --output-package "${OUTPUT}/informers" \
+  --go-header-file "${HEADER}" --output-base "${TMP}"
+
+cp -r "${TMP}/${MODULE}/." "${ROOT}/"
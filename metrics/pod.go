@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoUsage is returned for a pod whose metrics report no container usage, e.g. a pod that just
+// started, or only report its CPU or its memory.
+var ErrNoUsage = errors.New("no container usage reported")
+
+// ContainerUsage is the usage of one container of a pod.
+type ContainerUsage struct {
+	Name string
+	Usage
+}
+
+// PodUsage is the usage of a pod, the sum of the usage of its containers.
+type PodUsage struct {
+	Usage
+	// Containers is the breakdown of Usage, excluded containers aren't listed.
+	Containers []ContainerUsage
+}
+
+// Aggregator sums the usage of the containers of a pod.
+type Aggregator struct {
+	// Exclude lists the names of containers left out of the sum, e.g. sidecars like istio-proxy.
+	Exclude []string
+}
+
+// ParseExclude returns an Aggregator excluding a comma-separated list of container names.
+func ParseExclude(list string) Aggregator {
+	var aggregator Aggregator
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			aggregator.Exclude = append(aggregator.Exclude, name)
+		}
+	}
+	return aggregator
+}
+
+// Excluded reports whether a container is left out of the sum.
+func (a Aggregator) Excluded(container string) bool {
+	for _, name := range a.Exclude {
+		if name == container {
+			return true
+		}
+	}
+	return false
+}
+
+// Sum adds up the usage of the containers that aren't excluded. It returns ErrNoUsage when no
+// container is left.
+func (a Aggregator) Sum(containers []ContainerUsage) (PodUsage, error) {
+	var pod PodUsage
+	for _, container := range containers {
+		if a.Excluded(container.Name) {
+			continue
+		}
+		pod.Usage = pod.Usage.Add(container.Usage)
+		pod.Containers = append(pod.Containers, container)
+	}
+	if len(pod.Containers) == 0 {
+		return pod, ErrNoUsage
+	}
+	return pod, nil
+}
@@ -0,0 +1,302 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kube-flux/kube-flux/policy"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultPrometheusQueries average the usage of every container over 5 minutes, so that a single
+// spike doesn't drive scaling decisions.
+var DefaultPrometheusQueries = PrometheusQueries{
+	CPU:    `sum by (pod, container) (rate(container_cpu_usage_seconds_total{namespace="{{.Namespace}}", pod=~"{{.Pods}}", container!="", container!="POD", container!~"{{.Exclude}}"}[5m]))`,
+	Memory: `sum by (pod, container) (avg_over_time(container_memory_working_set_bytes{namespace="{{.Namespace}}", pod=~"{{.Pods}}", container!="", container!="POD", container!~"{{.Exclude}}"}[5m]))`,
+}
+
+// prometheusTimeout bounds a single query.
+const prometheusTimeout = 30 * time.Second
+
+// PrometheusQueries are the PromQL templates reading the usage of the pods of an importance class.
+// Each query must return samples labeled with pod: CPU in cores and Memory in bytes. Samples also
+// labeled with container break the usage of a pod down by container, otherwise the query must
+// return one sample per pod. The templates are executed with:
+//
+//	{{.Namespace}}: the namespace of the pods
+//	{{.Pods}}: a regular expression matching the pods, escaped for a double-quoted string
+//	{{.Exclude}}: a regular expression matching the containers left out, escaped the same way
+type PrometheusQueries struct {
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+}
+
+// PrometheusConfig is the content of the -prometheus-queries file: the queries of each importance
+// class, falling back to Default and then to DefaultPrometheusQueries.
+type PrometheusConfig struct {
+	Default PrometheusQueries            `json:"default"`
+	Classes map[string]PrometheusQueries `json:"classes"`
+}
+
+// LoadPrometheusConfig reads the queries of a YAML or JSON file.
+func LoadPrometheusConfig(path string) (*PrometheusConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config PrometheusConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &config, nil
+}
+
+type prometheusTemplates struct {
+	cpu    *template.Template
+	memory *template.Template
+}
+
+// Prometheus reads pod usage from the HTTP API of Prometheus, with PromQL configurable per
+// importance class, e.g. to smooth the usage of a class over a longer window.
+type Prometheus struct {
+	url        *url.URL
+	client     *http.Client
+	aggregator Aggregator
+	defaults   prometheusTemplates
+	classes    map[policy.Class]prometheusTemplates
+}
+
+// NewPrometheus creates a MetricsSource querying the Prometheus server at address, e.g.
+// http://prometheus-operated.monitoring:9090. The containers excluded by aggregator are passed
+// to the queries as {{.Exclude}}. config may be nil to use DefaultPrometheusQueries.
+func NewPrometheus(address string, client *http.Client, aggregator Aggregator, config *PrometheusConfig) (*Prometheus, error) {
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Prometheus address %q", address)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: prometheusTimeout}
+	}
+	if config == nil {
+		config = &PrometheusConfig{}
+	}
+
+	p := &Prometheus{url: u, client: client, aggregator: aggregator, classes: make(map[policy.Class]prometheusTemplates)}
+	if p.defaults, err = parseQueries("default", config.Default, DefaultPrometheusQueries); err != nil {
+		return nil, err
+	}
+	for name, queries := range config.Classes {
+		class, err := policy.ParseClass(name)
+		if err != nil {
+			return nil, err
+		}
+		if p.classes[class], err = parseQueries(string(class), queries, config.Default, DefaultPrometheusQueries); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// parseQueries parses the templates of queries, each empty query taken from the first fallback that has it.
+func parseQueries(name string, queries PrometheusQueries, fallbacks ...PrometheusQueries) (prometheusTemplates, error) {
+	for _, fallback := range fallbacks {
+		if queries.CPU == "" {
+			queries.CPU = fallback.CPU
+		}
+		if queries.Memory == "" {
+			queries.Memory = fallback.Memory
+		}
+	}
+	var templates prometheusTemplates
+	var err error
+	if templates.cpu, err = template.New(name + " cpu").Option("missingkey=error").Parse(queries.CPU); err != nil {
+		return templates, err
+	}
+	if templates.memory, err = template.New(name + " memory").Option("missingkey=error").Parse(queries.Memory); err != nil {
+		return templates, err
+	}
+	return templates, nil
+}
+
+// PodUsage runs the CPU and memory queries of every importance class.
+func (p *Prometheus) PodUsage(ctx context.Context, namespace string, pods map[policy.Class][]Pods) (map[string]PodUsage, error) {
+	usages := make(map[string]PodUsage)
+	for class, workloads := range pods {
+		var names []string
+		for _, workload := range workloads {
+			names = append(names, workload.Names...)
+		}
+		if len(names) == 0 {
+			continue
+		}
+		templates, ok := p.classes[class]
+		if !ok {
+			templates = p.defaults
+		}
+		data := struct{ Namespace, Pods, Exclude string }{
+			Namespace: namespace,
+			Pods:      promRegexp(names),
+			Exclude:   promRegexp(p.aggregator.Exclude),
+		}
+
+		cpu, err := p.query(ctx, templates.cpu, data)
+		if err != nil {
+			return nil, fmt.Errorf("CPU of class %s: %v", class, err)
+		}
+		memory, err := p.query(ctx, templates.memory, data)
+		if err != nil {
+			return nil, fmt.Errorf("memory of class %s: %v", class, err)
+		}
+		for _, name := range names {
+			usage, err := p.podUsage(cpu[name], memory[name])
+			if err == ErrNoUsage {
+				// no samples yet, e.g. just started
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("pod %s: %v", name, err)
+			}
+			usages[name] = usage
+		}
+	}
+	return usages, nil
+}
+
+// podUsage converts the CPU and memory samples of a pod, by container. It returns ErrNoUsage
+// unless both CPU and memory, of every container when broken down by container, have samples.
+func (p *Prometheus) podUsage(cpu, memory map[string]float64) (PodUsage, error) {
+	if len(cpu) == 0 || len(memory) == 0 {
+		return PodUsage{}, ErrNoUsage
+	}
+	_, cpuTotal := cpu[""]
+	_, memoryTotal := memory[""]
+	if cpuTotal || memoryTotal {
+		// not broken down by container, or not consistently
+		var usage Usage
+		for _, cores := range cpu {
+			usage.CPU += nanocores(cores)
+		}
+		for _, bytes := range memory {
+			usage.Memory += int64(math.Round(bytes))
+		}
+		return PodUsage{Usage: usage}, nil
+	}
+
+	if len(cpu) != len(memory) {
+		return PodUsage{}, ErrNoUsage
+	}
+	containers := make([]ContainerUsage, 0, len(cpu))
+	for name, cores := range cpu {
+		bytes, ok := memory[name]
+		if !ok {
+			return PodUsage{}, ErrNoUsage
+		}
+		containers = append(containers, ContainerUsage{Name: name, Usage: Usage{CPU: nanocores(cores), Memory: int64(math.Round(bytes))}})
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	return p.aggregator.Sum(containers)
+}
+
+// nanocores converts a CPU usage in cores to nanocores.
+func nanocores(cores float64) int64 {
+	return int64(math.Round(cores * 1e9))
+}
+
+// promRegexp returns a regular expression matching exactly the given names, escaped for a
+// double-quoted PromQL string. No name gives a regular expression matching nothing but "".
+func promRegexp(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return strings.ReplaceAll(strings.Join(quoted, "|"), `\`, `\\`)
+}
+
+// prometheusResponse is the envelope of the Prometheus HTTP API.
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			// Value is a [timestamp, "value"] pair.
+			Value [2]interface{} `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// query runs an instant query and returns its samples by pod and container, container being
+// empty for samples without a container label.
+func (p *Prometheus) query(ctx context.Context, query *template.Template, data interface{}) (map[string]map[string]float64, error) {
+	var promQL bytes.Buffer
+	if err := query.Execute(&promQL, data); err != nil {
+		return nil, err
+	}
+
+	endpoint := *p.url
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/api/v1/query"
+	form := url.Values{"query": {promQL.String()}}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response with status %s: %v", resp.Status, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("%s: %s", result.ErrorType, result.Error)
+	}
+	if result.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("expected a vector result, got %s", result.Data.ResultType)
+	}
+
+	samples := make(map[string]map[string]float64, len(result.Data.Result))
+	for _, sample := range result.Data.Result {
+		pod := sample.Metric["pod"]
+		if pod == "" {
+			return nil, fmt.Errorf("sample %v has no pod label, aggregate the query by pod", sample.Metric)
+		}
+		text, ok := sample.Value[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v of pod %s", sample.Value[1], pod)
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q of pod %s: %v", text, pod, err)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		if samples[pod] == nil {
+			samples[pod] = make(map[string]float64)
+		}
+		container := sample.Metric["container"]
+		if _, ok := samples[pod][container]; ok {
+			return nil, fmt.Errorf("several samples of pod %s and container %q, aggregate the query by pod", pod, container)
+		}
+		samples[pod][container] = value
+	}
+	return samples, nil
+}
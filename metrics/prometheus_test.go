@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/policy"
+)
+
+// sample is a sample of a fake Prometheus vector.
+type sample struct {
+	pod, container, value string
+}
+
+// fakePrometheus answers instant queries of CPU and memory with the given samples, and records
+// the queries it received.
+func fakePrometheus(t *testing.T, cpu, memory []sample, queries *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		query := r.FormValue("query")
+		*queries = append(*queries, query)
+		samples := memory
+		if strings.Contains(query, "cpu") {
+			samples = cpu
+		}
+		result := make([]map[string]interface{}, 0, len(samples))
+		for _, s := range samples {
+			metric := map[string]string{"pod": s.pod}
+			if s.container != "" {
+				metric["container"] = s.container
+			}
+			result = append(result, map[string]interface{}{"metric": metric, "value": []interface{}{1600000000, s.value}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "vector", "result": result},
+		})
+	}))
+}
+
+func TestPrometheusPodUsage(t *testing.T) {
+	tests := []struct {
+		name        string
+		aggregator  Aggregator
+		cpu, memory []sample
+		want        map[string]PodUsage
+	}{
+		{
+			name:   "by container",
+			cpu:    []sample{{"web-1", "app", "0.25"}, {"web-1", "log", "0.05"}},
+			memory: []sample{{"web-1", "app", "1000"}, {"web-1", "log", "24"}},
+			want: map[string]PodUsage{"web-1": {
+				Usage: Usage{CPU: 300000000, Memory: 1024},
+				Containers: []ContainerUsage{
+					{Name: "app", Usage: Usage{CPU: 250000000, Memory: 1000}},
+					{Name: "log", Usage: Usage{CPU: 50000000, Memory: 24}},
+				},
+			}},
+		},
+		{
+			name:       "excluded container",
+			aggregator: Aggregator{Exclude: []string{"istio-proxy"}},
+			cpu:        []sample{{"web-1", "app", "0.25"}, {"web-1", "istio-proxy", "0.1"}},
+			memory:     []sample{{"web-1", "app", "1000"}, {"web-1", "istio-proxy", "500"}},
+			want: map[string]PodUsage{"web-1": {
+				Usage:      Usage{CPU: 250000000, Memory: 1000},
+				Containers: []ContainerUsage{{Name: "app", Usage: Usage{CPU: 250000000, Memory: 1000}}},
+			}},
+		},
+		{
+			name:   "by pod",
+			cpu:    []sample{{"web-1", "", "0.5"}},
+			memory: []sample{{"web-1", "", "2048"}},
+			want:   map[string]PodUsage{"web-1": {Usage: Usage{CPU: 500000000, Memory: 2048}}},
+		},
+		{
+			name:   "missing memory",
+			cpu:    []sample{{"web-1", "app", "0.25"}, {"web-2", "app", "0.5"}},
+			memory: []sample{{"web-2", "app", "4096"}},
+			want: map[string]PodUsage{"web-2": {
+				Usage:      Usage{CPU: 500000000, Memory: 4096},
+				Containers: []ContainerUsage{{Name: "app", Usage: Usage{CPU: 500000000, Memory: 4096}}},
+			}},
+		},
+		{
+			name:   "missing container memory",
+			cpu:    []sample{{"web-1", "app", "0.25"}, {"web-1", "log", "0.05"}},
+			memory: []sample{{"web-1", "app", "1000"}},
+			want:   map[string]PodUsage{},
+		},
+		{
+			name:   "missing cpu",
+			memory: []sample{{"web-1", "app", "1000"}},
+			want:   map[string]PodUsage{},
+		},
+		{
+			name:   "NaN",
+			cpu:    []sample{{"web-1", "app", "NaN"}},
+			memory: []sample{{"web-1", "app", "1000"}},
+			want:   map[string]PodUsage{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var queries []string
+			server := fakePrometheus(t, test.cpu, test.memory, &queries)
+			defer server.Close()
+			source, err := NewPrometheus(server.URL, nil, test.aggregator, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pods := map[policy.Class][]Pods{policy.High: {{Names: []string{"web-1", "web-2"}}}}
+			got, err := source.PodUsage(context.Background(), "shop", pods)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if len(queries) != 2 {
+				t.Fatalf("got %d queries, want 2", len(queries))
+			}
+			for _, query := range queries {
+				if !strings.Contains(query, `namespace="shop"`) || !strings.Contains(query, `pod=~"web-1|web-2"`) {
+					t.Errorf("query %s doesn't select the pods of the namespace", query)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		cpu, memory []sample
+	}{
+		{name: "duplicate sample", cpu: []sample{{"web-1", "", "0.25"}, {"web-1", "", "0.5"}}},
+		{name: "invalid value", cpu: []sample{{"web-1", "", "a lot"}}},
+		{name: "missing pod label", cpu: []sample{{"", "", "0.25"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var queries []string
+			server := fakePrometheus(t, test.cpu, test.memory, &queries)
+			defer server.Close()
+			source, err := NewPrometheus(server.URL, nil, Aggregator{}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pods := map[policy.Class][]Pods{policy.Low: {{Names: []string{"web-1"}}}}
+			if _, err := source.PodUsage(context.Background(), "shop", pods); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "errorType": "bad_data", "error": "parse error"})
+	}))
+	defer server.Close()
+	source, err := NewPrometheus(server.URL, nil, Aggregator{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := map[policy.Class][]Pods{policy.Low: {{Names: []string{"web-1"}}}}
+	if _, err := source.PodUsage(context.Background(), "shop", pods); err == nil || !strings.Contains(err.Error(), "parse error") {
+		t.Errorf("got %v, want the error of Prometheus", err)
+	}
+}
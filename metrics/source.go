@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/kube-flux/kube-flux/policy"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	"k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// MetricsSource reads the usage of pods. Implementations are safe for concurrent use.
+type MetricsSource interface {
+	// PodUsage returns the usage of the pods of a namespace, by pod name. The pods to read are
+	// grouped by importance class, for sources that query each class differently, and by workload.
+	// Pods that have no metrics yet, e.g. just started, are missing from the result.
+	PodUsage(ctx context.Context, namespace string, pods map[policy.Class][]Pods) (map[string]PodUsage, error)
+}
+
+// Pods are the pods of a workload whose usage is read.
+type Pods struct {
+	// Selector selects the pods of the workload, every pod of the namespace when nil.
+	Selector labels.Selector
+	// Names are the names of the pods.
+	Names []string
+}
+
+// MetricsServer reads pod usage from the metrics.k8s.io API served by metrics-server. Every
+// read is a single instantaneous sample.
+type MetricsServer struct {
+	client     versioned.Interface
+	aggregator Aggregator
+}
+
+// NewMetricsServer creates a MetricsSource listing PodMetrics with client and summing their containers with aggregator.
+func NewMetricsServer(client versioned.Interface, aggregator Aggregator) *MetricsServer {
+	return &MetricsServer{client: client, aggregator: aggregator}
+}
+
+// NewMetricsServerForConfig creates a MetricsServer from a REST config.
+func NewMetricsServerForConfig(config *rest.Config, aggregator Aggregator) (*MetricsServer, error) {
+	client, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewMetricsServer(client, aggregator), nil
+}
+
+// PodUsage lists the PodMetrics of every workload with its label selector, once per selector,
+// so that only the metrics of the managed pods are read.
+func (s *MetricsServer) PodUsage(ctx context.Context, namespace string, pods map[policy.Class][]Pods) (map[string]PodUsage, error) {
+	wanted := make(map[string]bool)
+	selectors := make(map[string]bool)
+	for _, workloads := range pods {
+		for _, workload := range workloads {
+			if len(workload.Names) == 0 {
+				continue
+			}
+			for _, name := range workload.Names {
+				wanted[name] = true
+			}
+			selector := ""
+			if workload.Selector != nil {
+				selector = workload.Selector.String()
+			}
+			selectors[selector] = true
+		}
+	}
+
+	usages := make(map[string]PodUsage, len(wanted))
+	for selector := range selectors {
+		list, err := s.client.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		if err := s.sum(list.Items, wanted, usages); err != nil {
+			return nil, err
+		}
+	}
+	return usages, nil
+}
+
+// sum adds the usage of the wanted pods of a PodMetrics list to usages.
+func (s *MetricsServer) sum(items []metricsv1beta1.PodMetrics, wanted map[string]bool, usages map[string]PodUsage) error {
+	for _, podMetrics := range items {
+		if !wanted[podMetrics.GetName()] {
+			continue
+		}
+		containers := make([]ContainerUsage, 0, len(podMetrics.Containers))
+		for _, container := range podMetrics.Containers {
+			containers = append(containers, ContainerUsage{Name: container.Name, Usage: UsageOf(container.Usage)})
+		}
+		usage, err := s.aggregator.Sum(containers)
+		if err == ErrNoUsage {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("pod %s: %v", podMetrics.GetName(), err)
+		}
+		usages[podMetrics.GetName()] = usage
+	}
+	return nil
+}
+
+const (
+	// SourceMetricsServer reads pod usage from metrics-server.
+	SourceMetricsServer = "metrics-server"
+	// SourcePrometheus reads pod usage from Prometheus.
+	SourcePrometheus = "prometheus"
+)
+
+// Options are the command line options selecting the MetricsSource.
+type Options struct {
+	Source            string
+	PrometheusURL     string
+	PrometheusQueries string
+}
+
+// AddFlags registers the options on a flag set.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Source, "metrics-source", SourceMetricsServer, "Where pod usage is read from: metrics-server or prometheus")
+	fs.StringVar(&o.PrometheusURL, "prometheus-url", "", "Address of the Prometheus server read with -metrics-source=prometheus, e.g. http://prometheus-operated.monitoring:9090")
+	fs.StringVar(&o.PrometheusQueries, "prometheus-queries", "", "YAML file of the PromQL queries of each importance class; 5m averages when empty")
+}
+
+// NewSource creates the MetricsSource of the options, summing containers with aggregator.
+func (o *Options) NewSource(config *rest.Config, aggregator Aggregator) (MetricsSource, error) {
+	switch o.Source {
+	case "", SourceMetricsServer:
+		return NewMetricsServerForConfig(config, aggregator)
+	case SourcePrometheus:
+		if o.PrometheusURL == "" {
+			return nil, fmt.Errorf("-metrics-source=prometheus needs -prometheus-url")
+		}
+		var queries *PrometheusConfig
+		if o.PrometheusQueries != "" {
+			var err error
+			if queries, err = LoadPrometheusConfig(o.PrometheusQueries); err != nil {
+				return nil, err
+			}
+		}
+		return NewPrometheus(o.PrometheusURL, nil, aggregator, queries)
+	default:
+		return nil, fmt.Errorf("unknown metrics source %q", o.Source)
+	}
+}
@@ -0,0 +1,25 @@
+// Package metrics reads the CPU and memory usage of pods.
+package metrics
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Usage is the CPU and memory usage of a container or a pod.
+type Usage struct {
+	// CPU is in nanocores.
+	CPU int64
+	// Memory is in bytes.
+	Memory int64
+}
+
+// Add returns the sum of two usages.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{CPU: u.CPU + other.CPU, Memory: u.Memory + other.Memory}
+}
+
+// UsageOf converts the CPU and memory of a resource list, missing resources count as zero.
+func UsageOf(resources corev1.ResourceList) Usage {
+	return Usage{CPU: resources.Cpu().ScaledValue(resource.Nano), Memory: resources.Memory().Value()}
+}
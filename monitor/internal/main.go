@@ -2,57 +2,44 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
-	"os"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/kube-flux/kube-flux/metrics"
+	"github.com/kube-flux/kube-flux/policy"
+	"github.com/kube-flux/kube-flux/scaling"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/rest"
 	clientcmd "k8s.io/client-go/tools/clientcmd/api"
 )
 
-// cpuMap is a map stores importance factor and average CPU usage.
+// cpuMap is a map stores importance factor and average CPU usage, in nanocores.
 var (
 	cpuMap = make(map[string]float64)
 )
 
-// memoryMap is a map stores importance factor and average memory usage.
+// memoryMap is a map stores importance factor and average memory usage, in bytes.
 var (
 	memoryMap = make(map[string]float64)
 )
 
-// PodMetric stores the JSON Array of one single pod information.
-type PodMetric struct {
-	Kind       string `json:"kind"`
-	APIVersion string `json:"apiVersion"`
-	Metadata   struct {
-		Name              string    `json:"name"`
-		Namespace         string    `json:"namespace"`
-		SelfLink          string    `json:"selfLink"`
-		Imp               string    `json:"imp"`
-		CreationTimestamp time.Time `json:"creationTimestamp"`
-	} `json:"metadata"`
-	Timestamp  time.Time `json:"timestamp"`
-	Window     string    `json:"window"`
-	Containers []struct {
-		Name  string `json:"name"`
-		Usage struct {
-			CPU    string `json:"cpu"`
-			Memory string `json:"memory"`
-		} `json:"usage"`
-	} `json:"containers"`
-}
-
-var singlePodObj PodMetric // single pod structure object
+var (
+	// metricsSource reads the usage of pods from the metrics API.
+	metricsSource metrics.MetricsSource
+	// containerAggregator sums the usage of the containers of a pod, leaving out -exclude-containers.
+	containerAggregator metrics.Aggregator
+	// containerBreakdown prints the usage of every container, set by -container-usage.
+	containerBreakdown bool
+)
 
-// authenticate is used to authenticate Go-client with GKE cluster.
-func authenticate(filePath string, HostIp string) *kubernetes.Clientset {
+// authenticate is used to authenticate Go-client with GKE cluster, for the Kubernetes and the metrics APIs.
+func authenticate(filePath string, HostIp string) (*kubernetes.Clientset, metrics.MetricsSource) {
 	MasterUrl := "https://" + HostIp
 	ca, err := ioutil.ReadFile(filePath)
 	if err != nil {
@@ -68,7 +55,11 @@ func authenticate(filePath string, HostIp string) *kubernetes.Clientset {
 	if err != nil {
 		panic("Failed to authenticate IP: " + HostIp)
 	}
-	return clientSet
+	source, err := metrics.NewMetricsServerForConfig(config, containerAggregator)
+	if err != nil {
+		panic("Failed to create metrics client for IP: " + HostIp)
+	}
+	return clientSet, source
 }
 
 // printDeploymentInfo prints the cpu and memory average of pods in each the importance factor.
@@ -85,19 +76,25 @@ func printDeploymentInfo(clientSet *kubernetes.Clientset, namespace string) {
 		fmt.Printf("   Label: \t%s\n", dep.GetLabels()["app"])
 		MapLabel := "app=" + currLabel
 		pods, _ := clientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: MapLabel})
-		numOfPods := len(pods.Items)
-		fmt.Printf("   Total Number of pods: %d\n", numOfPods)
+		fmt.Printf("   Total Number of pods: %d\n", len(pods.Items))
+		usages, err := podUsages(namespace, MapLabel, pods.Items)
+		if err != nil {
+			panic(err.Error())
+		}
+		numOfPods := 0
 		currPodImp := ""
 		// loop through the pods
 		for j := range pods.Items {
 			currPod := pods.Items[j]
 			currPodImp = currPod.GetAnnotations()["imp"]
-			currPodName := currPod.GetName()
-			// calculate the sum of cpu and memory and save to map
-			err = sumPodUsage(currPodName, currPodImp, clientSet, namespace)
-			if err != nil {
-				panic(err.Error())
+			usage, ok := usages[currPod.GetName()]
+			if !ok {
+				// e.g. just started, left out of the average
+				continue
 			}
+			// calculate the sum of cpu and memory and save to map
+			sumPodUsage(currPod.GetName(), currPodImp, usage)
+			numOfPods++
 		}
 		// get the ave of the pods in each deployment
 		if numOfPods != 0 {
@@ -107,31 +104,42 @@ func printDeploymentInfo(clientSet *kubernetes.Clientset, namespace string) {
 	}
 }
 
-// sumPodUsage print single pod info, then sums the cpu and memory usage of pods in the same importance factor.
-func sumPodUsage(podName string, imp string, clientSet *kubernetes.Clientset, namespace string) error {
-	absPath := "apis/metrics.k8s.io/v1beta1/namespaces/" + namespace + "/pods/" + podName
-	data, err := clientSet.RESTClient().Get().AbsPath(absPath).DoRaw(context.TODO())
+// podUsages reads the usage of the pods of a deployment, selected by labelSelector, in a single
+// call to the metrics source, grouped by their imp annotation.
+func podUsages(namespace string, labelSelector string, pods []corev1.Pod) (map[string]metrics.PodUsage, error) {
+	selector, err := labels.Parse(labelSelector)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = json.Unmarshal(data, &singlePodObj)
-	if err != nil {
-		panic(err.Error())
+	byClass := make(map[policy.Class][]metrics.Pods)
+	for _, pod := range pods {
+		class, err := policy.ParseClass(pod.GetAnnotations()["imp"])
+		if err != nil {
+			// the source falls back to its default queries
+			class = ""
+		}
+		if len(byClass[class]) == 0 {
+			byClass[class] = []metrics.Pods{{Selector: selector}}
+		}
+		byClass[class][0].Names = append(byClass[class][0].Names, pod.GetName())
 	}
+	return metricsSource.PodUsage(context.TODO(), namespace, byClass)
+}
 
-	tempMemoryString := strings.TrimRight(singlePodObj.Containers[0].Usage.Memory, "Ki")
-	tempCPUString := strings.TrimRight(singlePodObj.Containers[0].Usage.CPU, "n")
-	currentCPUUsage, _ := strconv.ParseFloat(tempCPUString, 2)
-	currentMemoryUsage, _ := strconv.ParseFloat(tempMemoryString, 2)
-
+// sumPodUsage print single pod info, then sums the cpu and memory usage of pods in the same importance factor.
+func sumPodUsage(podName string, imp string, usage metrics.PodUsage) {
 	fmt.Printf("   Pod name: \t\t\t%s\n", podName)
 	fmt.Printf("   Current imp: \t\t%s\n", imp)
-	fmt.Printf("   Current CPU usage: \t\t%.2f\n", currentCPUUsage)
-	fmt.Printf("   Current Memory usage: \t%.2f\n", currentMemoryUsage)
+	fmt.Printf("   Current CPU usage: \t\t%dn\n", usage.CPU)
+	fmt.Printf("   Current Memory usage: \t%d bytes\n", usage.Memory)
+	if containerBreakdown {
+		for _, container := range usage.Containers {
+			fmt.Printf("     Container %s: \tCPU %dn, Memory %d bytes\n", container.Name, container.CPU, container.Memory)
+		}
+	}
 	//sum the CPU & memory usage in the same imp
-	cpuMap[imp] += currentCPUUsage
-	memoryMap[imp] += currentMemoryUsage
-	return err
+	cpuMap[imp] += float64(usage.CPU)
+	memoryMap[imp] += float64(usage.Memory)
 }
 
 // changeReplica changes the number of replica-sets of a certain deployment.
@@ -160,10 +168,12 @@ func changeReplica(clientSet *kubernetes.Clientset, namespace string, imp int32,
 		fmt.Printf("\t\t\tNothing need to be changed.\n")
 		return
 	}
-	// update the replica-set number
-	*(nginx.Spec.Replicas) = newReplicaNum
-	_, _ = clientSet.AppsV1().Deployments(namespace).Update(context.TODO(), &nginx, metav1.UpdateOptions{})
-	fmt.Printf("\t\t\tCurrent number replica-set after change: %d\n", *(nginx.Spec.Replicas))
+	// update the replica-set number through the scale subresource
+	if _, err := scaling.SetReplicas(context.TODO(), clientSet, namespace, nginx.GetName(), newReplicaNum); err != nil {
+		fmt.Printf("\t\t\tFailed to change the number of replica-set: %v\n", err)
+		return
+	}
+	fmt.Printf("\t\t\tCurrent number replica-set after change: %d\n", newReplicaNum)
 }
 
 // printCurrPodUsage loops through CPU map and memory map to print.
@@ -214,33 +224,26 @@ func aveCurrPodUsage(clientSet *kubernetes.Clientset, namespace string) error {
 		currLabel := dep.GetLabels()["app"]
 		MapLabel := "app=" + currLabel
 		pods, _ := clientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: MapLabel})
-		numOfPods := len(pods.Items)
+		usages, err := podUsages(namespace, MapLabel, pods.Items)
+		if err != nil {
+			return err
+		}
+		numOfPods := 0
 		currPodImp := ""
 		// loop through the pods
 		for j := range pods.Items {
 			currPod := pods.Items[j]
 			currPodImp = currPod.GetAnnotations()["imp"]
-			currPodName := currPod.GetName()
 			// calculate the average of cpu and memory and save to map
-			absPath := "apis/metrics.k8s.io/v1beta1/namespaces/" + namespace + "/pods/" + currPodName
-			data, err := clientSet.RESTClient().Get().AbsPath(absPath).DoRaw(context.TODO())
-			if err != nil {
-				return err
+			usage, ok := usages[currPod.GetName()]
+			if !ok {
+				// e.g. just started, left out of the average
+				continue
 			}
-			err = json.Unmarshal(data, &singlePodObj)
-			if err != nil {
-				panic(err.Error())
-			}
-			if err != nil {
-				panic(err.Error())
-			}
-			tempMemoryString := strings.TrimRight(singlePodObj.Containers[0].Usage.Memory, "Ki")
-			tempCPUString := strings.TrimRight(singlePodObj.Containers[0].Usage.CPU, "n")
-			currentCPUUsage, _ := strconv.ParseFloat(tempCPUString, 2)
-			currentMemoryUsage, _ := strconv.ParseFloat(tempMemoryString, 2)
 			//sum the CPU & memory usage in the same imp
-			cpuMap[currPodImp] += currentCPUUsage
-			memoryMap[currPodImp] += currentMemoryUsage
+			cpuMap[currPodImp] += float64(usage.CPU)
+			memoryMap[currPodImp] += float64(usage.Memory)
+			numOfPods++
 		}
 		if numOfPods != 0 {
 			cpuMap[currPodImp] = cpuMap[currPodImp] / float64(numOfPods)
@@ -251,11 +254,17 @@ func aveCurrPodUsage(clientSet *kubernetes.Clientset, namespace string) error {
 }
 
 func main() {
-	filePath := os.Args[1]                         //Pass .pem file as a command line argument
-	clusterIP := os.Args[2]                        //Pass cluster IP address
-	currNamespace := os.Args[3]                    //Pass the namespace
-	clientSet := authenticate(filePath, clusterIP) //Authenticates with the GCP cluster
-	printDeploymentInfo(clientSet, currNamespace)  //Print the usage of CPU memory in each imp
+	excludeContainers := flag.String("exclude-containers", "", "Comma-separated names of containers left out of pod usage, e.g. istio-proxy")
+	flag.BoolVar(&containerBreakdown, "container-usage", false, "Print the usage of every container of the evaluated pods")
+	flag.Parse()
+	containerAggregator = metrics.ParseExclude(*excludeContainers)
+
+	filePath := flag.Arg(0)                                //Pass .pem file as a command line argument
+	clusterIP := flag.Arg(1)                               //Pass cluster IP address
+	currNamespace := flag.Arg(2)                           //Pass the namespace
+	clientSet, source := authenticate(filePath, clusterIP) //Authenticates with the GCP cluster
+	metricsSource = source
+	printDeploymentInfo(clientSet, currNamespace) //Print the usage of CPU memory in each imp
 	// calculate the usage and print
 	fmt.Printf("\n----------------------------- [Usage] ----------------------------\n")
 	printCurrPodUsage()
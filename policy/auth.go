@@ -0,0 +1,370 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Role is what an authenticated user may do with the policy API.
+type Role int
+
+const (
+	// NoRole may not use the policy API.
+	NoRole Role = iota
+	// Reader may GET and watch the policy, its schema and its history.
+	Reader
+	// Writer may also PUT the policy.
+	Writer
+)
+
+// DefaultWriterGroup is the group whose members may change the policy unless configured otherwise.
+const DefaultWriterGroup = "kube-flux:writers"
+
+const (
+	// tokenReviewCacheSize bounds the number of TokenReview results kept.
+	tokenReviewCacheSize = 1024
+	// DefaultTokenReviewTTL and DefaultTokenReviewDeniedTTL are how long authenticated and
+	// unauthenticated TokenReview results are cached, the defaults of the kube-apiserver webhook.
+	DefaultTokenReviewTTL       = 2 * time.Minute
+	DefaultTokenReviewDeniedTTL = 10 * time.Second
+)
+
+// ErrNoAuthenticator is returned by NewAuth when no authenticator is configured without -insecure.
+var ErrNoAuthenticator = errors.New("the policy API needs -tokens-file or -token-review, or -insecure to serve it without authentication")
+
+// User is an authenticated client of the policy API.
+type User struct {
+	Name   string
+	Groups []string
+}
+
+// TokenAuthenticator authenticates bearer tokens. It returns false for tokens it doesn't know.
+type TokenAuthenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (*User, bool, error)
+}
+
+// TokenFile authenticates the static tokens of a CSV file in the format of the
+// kube-apiserver token file: token,user,uid,"group1,group2".
+type TokenFile map[string]*User
+
+// LoadTokenFile reads a static tokens file. Empty lines and lines starting with # are skipped.
+func LoadTokenFile(path string) (TokenFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	tokens := make(TokenFile)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 || record[0] == "" || record[1] == "" {
+			return nil, fmt.Errorf("%s: record %d: expected token,user,uid[,groups]", path, line)
+		}
+		user := &User{Name: record[1]}
+		if len(record) > 3 && record[3] != "" {
+			for _, group := range strings.Split(record[3], ",") {
+				user.Groups = append(user.Groups, strings.TrimSpace(group))
+			}
+		}
+		tokens[record[0]] = user
+	}
+	return tokens, nil
+}
+
+// AuthenticateToken looks the token up in the file.
+func (tokens TokenFile) AuthenticateToken(_ context.Context, token string) (*User, bool, error) {
+	user, ok := tokens[token]
+	return user, ok, nil
+}
+
+// TokenReview authenticates Kubernetes service account and user tokens through the TokenReview API.
+// Results are cached by the hash of the token, so that every request doesn't reach the API server.
+type TokenReview struct {
+	ClientSet kubernetes.Interface
+	// Audiences the token must be issued for, the API server's when empty.
+	Audiences []string
+	// TTL and DeniedTTL are how long authenticated and unauthenticated results are cached,
+	// DefaultTokenReviewTTL and DefaultTokenReviewDeniedTTL when zero.
+	TTL       time.Duration
+	DeniedTTL time.Duration
+
+	cache *cache.LRUExpireCache
+}
+
+// reviewResult is a cached TokenReview result, user is nil when the token wasn't authenticated.
+type reviewResult struct {
+	user *User
+}
+
+// NewTokenReview creates a TokenReview with the default cache TTLs.
+func NewTokenReview(clientSet kubernetes.Interface) *TokenReview {
+	return &TokenReview{
+		ClientSet: clientSet,
+		TTL:       DefaultTokenReviewTTL,
+		DeniedTTL: DefaultTokenReviewDeniedTTL,
+		cache:     cache.NewLRUExpireCache(tokenReviewCacheSize),
+	}
+}
+
+// AuthenticateToken returns the cached result of the token, else asks the API server to review it.
+// Failed reviews aren't cached.
+func (review *TokenReview) AuthenticateToken(ctx context.Context, token string) (*User, bool, error) {
+	key := sha256.Sum256([]byte(token))
+	if review.cache != nil {
+		if cached, ok := review.cache.Get(key); ok {
+			result := cached.(reviewResult)
+			return result.user, result.user != nil, nil
+		}
+	}
+
+	result, err := review.ClientSet.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: review.Audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	var user *User
+	ttl := review.DeniedTTL
+	if result.Status.Authenticated {
+		user = &User{Name: result.Status.User.Username, Groups: result.Status.User.Groups}
+		ttl = review.TTL
+	}
+	if review.cache != nil && ttl > 0 {
+		review.cache.Add(key, reviewResult{user: user}, ttl)
+	}
+	return user, user != nil, nil
+}
+
+// TokenAuthenticators tries each authenticator in turn until one knows the token.
+type TokenAuthenticators []TokenAuthenticator
+
+// AuthenticateToken returns the user of the first authenticator that knows the token.
+func (authenticators TokenAuthenticators) AuthenticateToken(ctx context.Context, token string) (*User, bool, error) {
+	var errs []string
+	for _, authenticator := range authenticators {
+		user, ok, err := authenticator.AuthenticateToken(ctx, token)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if ok {
+			return user, true, nil
+		}
+	}
+	if len(errs) != 0 {
+		return nil, false, fmt.Errorf("failed to authenticate token: %s", strings.Join(errs, "; "))
+	}
+	return nil, false, nil
+}
+
+// Auth authenticates and authorizes requests to the policy API and answers CORS requests.
+type Auth struct {
+	// Authenticator authenticates bearer tokens; every request is denied when nil, unless Insecure.
+	Authenticator TokenAuthenticator
+	// Insecure lets every request through as a writer when there is no Authenticator.
+	Insecure bool
+	// ReaderGroups may read the policy, every authenticated user may when empty.
+	ReaderGroups []string
+	// WriterGroups may read and change the policy.
+	WriterGroups []string
+	// AllowedOrigins are the origins of browsers allowed to call the API, any origin when it contains "*",
+	// none when empty.
+	AllowedOrigins []string
+}
+
+type userKey struct{}
+
+// UserFrom returns the authenticated user of a request, nil when authentication is disabled with Insecure.
+func UserFrom(ctx context.Context) *User {
+	user, _ := ctx.Value(userKey{}).(*User)
+	return user
+}
+
+// Role returns the role of an authenticated user.
+func (auth *Auth) Role(user *User) Role {
+	if hasGroup(user, auth.WriterGroups) {
+		return Writer
+	}
+	if len(auth.ReaderGroups) == 0 || hasGroup(user, auth.ReaderGroups) {
+		return Reader
+	}
+	return NoRole
+}
+
+func hasGroup(user *User, groups []string) bool {
+	for _, group := range user.Groups {
+		for _, allowed := range groups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Handler wraps a handler of the policy API. OPTIONS requests are answered with the CORS headers,
+// GET requests need the Reader role and any other method the Writer role.
+func (auth *Auth) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.setCORSHeaders(w, r)
+		if r.Method == "OPTIONS" {
+			return
+		}
+		if auth.Authenticator == nil {
+			if !auth.Insecure {
+				WriteProblem(w, r, http.StatusUnauthorized, "No authentication is configured.")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kube-flux"`)
+			WriteProblem(w, r, http.StatusUnauthorized, "A bearer token is required.")
+			return
+		}
+		user, ok, err := auth.Authenticator.AuthenticateToken(r.Context(), token)
+		if err != nil {
+			log.Println("func", "Auth.Handler", "Failed to authenticate token", "err:", err)
+			WriteProblem(w, r, http.StatusInternalServerError, "Failed to authenticate the token.")
+			return
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kube-flux", error="invalid_token"`)
+			WriteProblem(w, r, http.StatusUnauthorized, "The bearer token is invalid.")
+			return
+		}
+
+		required := Writer
+		if r.Method == "GET" || r.Method == "HEAD" {
+			required = Reader
+		}
+		if auth.Role(user) < required {
+			log.Println("func", "Auth.Handler", "Denied", r.Method, r.URL.Path, "to", user.Name)
+			WriteProblem(w, r, http.StatusForbidden, fmt.Sprintf("User %q may not %s %s.", user.Name, r.Method, r.URL.Path))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
+
+// bearerToken returns the token of the Authorization header. Tokens in query parameters aren't
+// accepted, they end up in access logs and browser histories.
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+func (auth *Auth) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	for _, allowed := range auth.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			if allowed == "*" {
+				origin = "*"
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "DELETE, GET, OPTIONS, POST, PUT")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-Requester, X-Change-Reason, Last-Event-ID, If-Match, If-None-Match")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
+			return
+		}
+	}
+}
+
+// AuthOptions are the command line options configuring Auth.
+type AuthOptions struct {
+	TokensFile   string
+	TokenReview  bool
+	ReaderGroups string
+	WriterGroups string
+	CORSOrigins  string
+	Insecure     bool
+}
+
+// AddFlags registers the options on a flag set.
+func (o *AuthOptions) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.TokensFile, "tokens-file", "", "Static bearer tokens of the policy API, one token,user,uid,\"group1,group2\" per line")
+	fs.BoolVar(&o.TokenReview, "token-review", false, "Authenticate Kubernetes bearer tokens of the policy API with the TokenReview API")
+	fs.StringVar(&o.ReaderGroups, "reader-groups", "", "Comma-separated groups allowed to read the policy; every authenticated user when empty")
+	fs.StringVar(&o.WriterGroups, "writer-groups", DefaultWriterGroup, "Comma-separated groups allowed to change the policy")
+	fs.StringVar(&o.CORSOrigins, "cors-origins", "", "Comma-separated origins allowed to call the policy API from a browser, * for any; none when empty")
+	fs.BoolVar(&o.Insecure, "insecure", false, "Serve the policy API without authentication when there is no -tokens-file nor -token-review, every caller may then change the policy")
+}
+
+// NewAuth creates the Auth of the options. clientSet is only used with TokenReview.
+// It fails with ErrNoAuthenticator when neither a tokens file nor TokenReview is configured,
+// unless Insecure disables authentication.
+func (o *AuthOptions) NewAuth(clientSet kubernetes.Interface) (*Auth, error) {
+	auth := &Auth{
+		ReaderGroups:   splitList(o.ReaderGroups),
+		WriterGroups:   splitList(o.WriterGroups),
+		AllowedOrigins: splitList(o.CORSOrigins),
+	}
+
+	var authenticators TokenAuthenticators
+	if o.TokensFile != "" {
+		tokens, err := LoadTokenFile(o.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, tokens)
+	}
+	if o.TokenReview {
+		if clientSet == nil {
+			return nil, fmt.Errorf("TokenReview needs a Kubernetes client")
+		}
+		authenticators = append(authenticators, NewTokenReview(clientSet))
+	}
+	if len(authenticators) == 0 {
+		if !o.Insecure {
+			return nil, ErrNoAuthenticator
+		}
+		log.Println("func", "NewAuth", "-insecure without -tokens-file nor -token-review, the policy API is not authenticated")
+		auth.Insecure = true
+		return auth, nil
+	}
+	auth.Authenticator = authenticators
+	return auth, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
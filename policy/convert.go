@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// v0Policy is the union of the unversioned payloads written before v1: Zeus stored
+// Status and UpdatedAt, the controller and the UI sent Status and Factor, the latter
+// sometimes as the string "null".
+type v0Policy struct {
+	Status    string
+	UpdatedAt string
+	Factor    json.RawMessage
+}
+
+// legacyTimeLayout is the layout of time.Time.String(), which Zeus used for UpdatedAt.
+const legacyTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// Decode decodes a policy payload of any version into the current model.
+func Decode(data []byte) (*Policy, error) {
+	var version struct{ APIVersion string }
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+
+	switch version.APIVersion {
+	case APIVersion:
+		var p Policy
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&p); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	case "":
+		var legacy v0Policy
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, err
+		}
+		return convertV0(&legacy)
+	default:
+		return nil, fmt.Errorf("unsupported policy apiVersion %q", version.APIVersion)
+	}
+}
+
+// convertV0 converts a legacy payload into the current model.
+// Unknown status and class names are kept as is and rejected by Validate.
+func convertV0(legacy *v0Policy) (*Policy, error) {
+	status, err := ParseStatus(legacy.Status)
+	if err != nil {
+		status = Status(legacy.Status)
+	}
+	factors, err := convertV0Factors(legacy.Factor)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{
+		APIVersion: APIVersion,
+		Status:     status,
+		Factor:     factors,
+		UpdatedAt:  parseV0Time(legacy.UpdatedAt),
+	}, nil
+}
+
+// convertV0Factors converts a legacy factor map keyed by free-form status and class names.
+// A missing factor, JSON null and the string "null" sent by the UI all mean no factors.
+func convertV0Factors(raw json.RawMessage) (Factors, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) || bytes.Equal(raw, []byte(`"null"`)) {
+		return nil, nil
+	}
+
+	var legacy map[string]map[string]int32
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return nil, fmt.Errorf("invalid factor: %v", err)
+	}
+	return ConvertFactors(legacy), nil
+}
+
+// ConvertFactors converts a factor map keyed by status and class names, as found in v0
+// payloads and the EnergyPolicy resource, into Factors.
+// Unknown status and class names are kept as is and rejected by Validate.
+func ConvertFactors(legacy map[string]map[string]int32) Factors {
+	if legacy == nil {
+		return nil
+	}
+	factors := make(Factors, len(legacy))
+	for statusName, classes := range legacy {
+		status, err := ParseStatus(statusName)
+		if err != nil {
+			status = Status(statusName)
+		}
+		factors[status] = make(map[Class]int32, len(classes))
+		for className, replicas := range classes {
+			class, err := ParseClass(className)
+			if err != nil {
+				class = Class(className)
+			}
+			factors[status][class] = replicas
+		}
+	}
+	return factors
+}
+
+// parseV0Time parses a legacy UpdatedAt, returning the zero time if it can't be parsed.
+func parseV0Time(s string) time.Time {
+	// drop the monotonic clock reading, e.g. " m=+0.000123"
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	for _, layout := range []string{time.RFC3339Nano, legacyTimeLayout} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Status
+		wantErr bool
+	}{
+		{in: "Green", want: Green},
+		{in: "brown", want: Brown},
+		{in: " BLACK ", want: Black},
+		{in: "Yellow", want: Brown},
+		{in: "red", want: Black},
+		{in: "Blue", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseStatus(test.in)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseStatus(%q) = %q, %v, want %q, error %t", test.in, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestParseClass(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Class
+		wantErr bool
+	}{
+		{in: "High", want: High},
+		{in: "medium", want: Medium},
+		{in: "LOW", want: Low},
+		{in: "1", want: High},
+		{in: "2", want: Medium},
+		{in: "3", want: Low},
+		{in: "top", want: High},
+		{in: "4", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseClass(test.in)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseClass(%q) = %q, %v, want %q, error %t", test.in, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	updatedAt := time.Date(2020, 11, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		data    string
+		want    *Policy
+		wantErr bool
+	}{
+		{
+			name: "v1",
+			data: `{"APIVersion": "v1", "Status": "Brown", "Factor": {"Brown": {"High": 4, "Medium": 2, "Low": 1}}, "UpdatedAt": "2020-11-02T15:04:05Z"}`,
+			want: &Policy{APIVersion: APIVersion, Status: Brown, Factor: Factors{Brown: {High: 4, Medium: 2, Low: 1}}, UpdatedAt: updatedAt},
+		},
+		{
+			name: "v1 percentages",
+			data: `{"APIVersion": "v1", "Status": "Black", "Percentages": {"Black": {"Low": {"Percent": 50, "Min": 1}}}}`,
+			want: &Policy{APIVersion: APIVersion, Status: Black, Percentages: Percentages{Black: {Low: {Percent: 50, Min: int32Ptr(1)}}}},
+		},
+		{
+			name:    "v1 unknown field",
+			data:    `{"APIVersion": "v1", "Status": "Green", "Factors": {}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown apiVersion",
+			data:    `{"APIVersion": "v2", "Status": "Green"}`,
+			wantErr: true,
+		},
+		{
+			name: "v0 of Zeus",
+			data: `{"Status": "Green", "UpdatedAt": "2020-11-02 15:04:05.000000001 +0000 UTC m=+0.000123"}`,
+			want: &Policy{APIVersion: APIVersion, Status: Green, UpdatedAt: updatedAt.Add(time.Nanosecond)},
+		},
+		{
+			name: "v0 of the UI",
+			data: `{"Status": "Yellow", "Factor": "null"}`,
+			want: &Policy{APIVersion: APIVersion, Status: Brown},
+		},
+		{
+			name: "v0 of the controller",
+			data: `{"Status": "red", "Factor": {"Red": {"1": 3, "2": 2, "3": 1}, "green": {"top": 10}}}`,
+			want: &Policy{APIVersion: APIVersion, Status: Black, Factor: Factors{Black: {High: 3, Medium: 2, Low: 1}, Green: {High: 10}}},
+		},
+		{
+			name: "v0 unknown names are kept for Validate",
+			data: `{"Status": "Blue", "Factor": {"Blue": {"Urgent": 1}}}`,
+			want: &Policy{APIVersion: APIVersion, Status: "Blue", Factor: Factors{"Blue": {"Urgent": 1}}},
+		},
+		{
+			name: "v0 unparsable time",
+			data: `{"Status": "Green", "UpdatedAt": "yesterday"}`,
+			want: &Policy{APIVersion: APIVersion, Status: Green},
+		},
+		{
+			name:    "v0 invalid factor",
+			data:    `{"Status": "Green", "Factor": [1, 2, 3]}`,
+			wantErr: true,
+		},
+		{
+			name:    "not JSON",
+			data:    `Status: Green`,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Decode([]byte(test.data))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.UpdatedAt.Equal(test.want.UpdatedAt) {
+				t.Errorf("got UpdatedAt %v, want %v", got.UpdatedAt, test.want.UpdatedAt)
+			}
+			got.UpdatedAt = test.want.UpdatedAt
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
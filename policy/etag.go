@@ -0,0 +1,33 @@
+package policy
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrPreconditionFailed is returned when an If-Match precondition doesn't match the current revision.
+var ErrPreconditionFailed = errors.New("the policy changed since the revision given in If-Match")
+
+// ETag returns the strong entity tag of a policy revision.
+func ETag(revision uint64) string {
+	return `"` + strconv.FormatUint(revision, 10) + `"`
+}
+
+// MatchesETag reports whether an If-Match or If-None-Match header value lists the entity tag of
+// revision or is "*". Weak tags never match. An empty header matches nothing.
+func MatchesETag(header string, revision uint64) bool {
+	etag := ETag(revision)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch reports whether a request with the If-Match header value may change a policy at revision.
+func IfMatch(header string, revision uint64) bool {
+	return header == "" || MatchesETag(header, revision)
+}
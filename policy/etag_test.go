@@ -0,0 +1,50 @@
+package policy
+
+import "testing"
+
+func TestETag(t *testing.T) {
+	if got := ETag(42); got != `"42"` {
+		t.Errorf(`ETag(42) = %s, want "42"`, got)
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		header   string
+		revision uint64
+		want     bool
+	}{
+		{header: `"3"`, revision: 3, want: true},
+		{header: `"3"`, revision: 4, want: false},
+		{header: `*`, revision: 7, want: true},
+		{header: `"1", "2" ,"3"`, revision: 2, want: true},
+		{header: `"1", "2"`, revision: 3, want: false},
+		{header: `W/"3"`, revision: 3, want: false},
+		{header: `3`, revision: 3, want: false},
+		{header: ``, revision: 0, want: false},
+	}
+	for _, test := range tests {
+		if got := MatchesETag(test.header, test.revision); got != test.want {
+			t.Errorf("MatchesETag(%q, %d) = %t, want %t", test.header, test.revision, got, test.want)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header   string
+		revision uint64
+		want     bool
+	}{
+		{header: ``, revision: 5, want: true},
+		{header: `"5"`, revision: 5, want: true},
+		{header: `"4"`, revision: 5, want: false},
+		{header: `*`, revision: 5, want: true},
+		{header: `W/"5"`, revision: 5, want: false},
+	}
+	for _, test := range tests {
+		if got := IfMatch(test.header, test.revision); got != test.want {
+			t.Errorf("IfMatch(%q, %d) = %t, want %t", test.header, test.revision, got, test.want)
+		}
+	}
+}
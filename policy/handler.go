@@ -3,6 +3,7 @@ package policy
 import (
 	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
@@ -17,17 +18,16 @@ const (
 )
 
 func getDefaultPolicyByteArray() []byte {
-	defaultPolicy := &Policy{
-		Status:    Green,
-		UpdatedAt: time.Now().String(),
-	}
-
-	policyByteArray, _ := json.Marshal(defaultPolicy)
+	policyByteArray, _ := json.Marshal(Default())
 	return policyByteArray
 }
 
 type policyHandler struct {
 	db *bolt.DB
+	// broadcaster streams changes to watchers, the resource version is the ID of the latest change.
+	broadcaster *Broadcaster
+	// Limits bound the factors accepted on PUT.
+	Limits Limits
 }
 
 func NewPolicyHandler() (*policyHandler, error) {
@@ -39,7 +39,15 @@ func NewPolicyHandler() (*policyHandler, error) {
 	}
 
 	// Initial Policy in database
+	var resourceVersion uint64
 	err = db.Update(func(tx *bolt.Tx) error {
+		history, err := tx.CreateBucketIfNotExists([]byte(historyBucket))
+		if err != nil {
+			log.Println("func", "NewPolicyHandler", "Failed to create history bucket", "err:", err)
+			return err
+		}
+		resourceVersion = history.Sequence()
+
 		log.Println("func", "NewPolicyHandler", "Creating bucket")
 		bucket, err := tx.CreateBucketIfNotExists([]byte(policyBucket))
 		if err != nil {
@@ -47,6 +55,12 @@ func NewPolicyHandler() (*policyHandler, error) {
 			return err
 		}
 
+		// Keep the Policy of an existing database, legacy payloads are converted when read
+		if bucket.Get([]byte("Policy")) != nil {
+			log.Println("func", "NewPolicyHandler", "Found existing Policy")
+			return nil
+		}
+
 		if err = bucket.Put([]byte("Policy"), getDefaultPolicyByteArray()); err != nil {
 			log.Println("func", "NewPolicyHandler", "Failed to put default status", "err:", err)
 			return err
@@ -59,94 +73,170 @@ func NewPolicyHandler() (*policyHandler, error) {
 		return nil, err
 	}
 
-	return &policyHandler{db: db}, nil
+	return &policyHandler{db: db, broadcaster: NewBroadcaster(resourceVersion), Limits: DefaultLimits}, nil
 }
 
 func (handler *policyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Println("func", "ServeHTTP", "Start handling policy request")
 
-	// Setup response
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS, PUT")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-
-	if r.Method == "OPTIONS" {
+	// CORS and OPTIONS are handled by Auth
+	if r.Method == "GET" && IsWatch(r) {
+		log.Println("func", "ServeHTTP", "Handling watch request")
+		ServeWatch(w, r, handler.broadcaster, handler.readPolicy)
 		return
 	}
 
 	if r.Method == "GET" {
 		log.Println("func", "ServeHTTP", "Handling GET request")
-		err := handler.db.View(func(tx *bolt.Tx) error {
-			bucket := tx.Bucket([]byte(policyBucket))
-			if bucket == nil {
-				err := errors.New("policy bucket doesn't exist")
-				log.Println("func", "ServeHTTP", "Failed to find bucket", "err:", err)
-				return err
-			}
-
-			var policy Policy
-			if err := json.Unmarshal(bucket.Get([]byte("Policy")), &policy); err != nil {
-				log.Println("func", "ServeHTTP", "err:", err)
-				return err
-			}
-
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(policy); err != nil {
-				log.Println("func", "ServeHTTP", "Failed to write policy to writer", "err:", err)
-				return err
-			}
-			log.Println("func", "ServeHTTP", "Response written")
-			return nil
-		})
+		policy, err := handler.readPolicy()
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, "Failed to read the policy.")
+			return
+		}
+
+		w.Header().Set("ETag", ETag(policy.Revision))
+		if MatchesETag(r.Header.Get("If-None-Match"), policy.Revision) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(policy); err != nil {
+			log.Println("func", "ServeHTTP", "Failed to write policy to writer", "err:", err)
+			return
 		}
+		log.Println("func", "ServeHTTP", "Response written")
 		return
 	}
 
 	if r.Method == "PUT" {
 		log.Println("func", "ServeHTTP", "Handling PUT request")
-		err := handler.db.Update(func(tx *bolt.Tx) error {
-			// Get bucket
-			log.Println("func", "NewPolicyHandler", "Getting bucket")
-			bucket := tx.Bucket([]byte(policyBucket))
-			if bucket == nil {
-				err := errors.New("policy bucket doesn't exist")
-				log.Println("func", "ServeHTTP", "Failed to find bucket", "err:", err)
-				return err
-			}
 
-			// Get Status from request
-			log.Println("func", "NewPolicyHandler", "Decoding request body")
-			decoder := json.NewDecoder(r.Body)
-			var policy Policy
-			if err := decoder.Decode(&policy); err != nil {
-				err := errors.New("failed to decode to Policy")
-				log.Println("func", "ServeHTTP", "err:", err)
+		// Get Status from request, v0 payloads are converted to the current version
+		log.Println("func", "ServeHTTP", "Decoding request body")
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.Println("func", "ServeHTTP", "Failed to read request body", "err:", err)
+			WriteProblem(w, r, http.StatusBadRequest, "Failed to read the request body.")
+			return
+		}
+		policy, err := Decode(body)
+		if err != nil {
+			log.Println("func", "ServeHTTP", "Failed to decode to Policy", "err:", err)
+			WriteProblem(w, r, http.StatusBadRequest, "Failed to decode the policy: "+err.Error())
+			return
+		}
+		if err := policy.Validate(handler.Limits); err != nil {
+			log.Println("func", "ServeHTTP", "err:", err)
+			WriteValidationProblem(w, r, err)
+			return
+		}
+
+		var change *Change
+		err = handler.db.Update(func(tx *bolt.Tx) error {
+			previous, err := getPolicy(tx)
+			if err != nil {
 				return err
 			}
+			if !IfMatch(r.Header.Get("If-Match"), previous.Revision) {
+				return ErrPreconditionFailed
+			}
+			// Keep the stored factors when the request has none, e.g. from the UI
+			if len(policy.Factor) == 0 {
+				policy.Factor = previous.Factor
+			}
+			// and the stored percentages when it has none, {} removes them
+			if policy.Percentages == nil {
+				policy.Percentages = previous.Percentages
+			}
 
 			// Update Status
-			policy.UpdatedAt = time.Now().String()
-			policyByteArray, err := json.Marshal(policy)
-			if err != nil {
-				log.Println("func", "NewPolicyHandler", "Failed to encode policy struct", "err:", err)
+			policy.APIVersion = APIVersion
+			policy.UpdatedAt = time.Now()
+			if policy.Revision, err = nextChangeID(tx); err != nil {
 				return err
 			}
-			log.Println("func", "NewPolicyHandler", "Updating Policy")
-			if err := bucket.Put([]byte("Policy"), policyByteArray); err != nil {
-				log.Println("func", "NewPolicyHandler", "Failed to put Policy", "err:", err)
+			if err := putPolicy(tx, policy); err != nil {
 				return err
 			}
 
-			w.WriteHeader(http.StatusNoContent)
-			log.Println("func", "NewPolicyHandler", "Updated Policy", string(bucket.Get([]byte("Status"))), string(bucket.Get([]byte("UpdatedAt"))))
-			return nil
+			// Record the change in the history
+			change = &Change{
+				ID:        policy.Revision,
+				Previous:  previous,
+				Current:   policy,
+				ChangedAt: policy.UpdatedAt,
+				Requester: requester(r),
+				Reason:    changeReason(r),
+			}
+			return appendChange(tx, change)
 		})
+		if err == ErrPreconditionFailed {
+			log.Println("func", "ServeHTTP", "err:", err)
+			WriteProblem(w, r, http.StatusPreconditionFailed, "The policy was changed since the revision in If-Match, get it and retry.")
+			return
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, "Failed to update the policy.")
+			return
 		}
+
+		handler.broadcaster.Publish(change.ID, policy)
+		w.Header().Set("ETag", ETag(policy.Revision))
+		w.WriteHeader(http.StatusNoContent)
+		log.Println("func", "ServeHTTP", "Updated Policy", policy.Status, policy.UpdatedAt)
 		return
 	}
-	log.Println("func", "ServeHTTP", "Finished handling policy request")
+
+	w.Header().Set("Allow", "GET, OPTIONS, PUT")
+	WriteProblem(w, r, http.StatusMethodNotAllowed, "Method "+r.Method+" is not supported.")
+}
+
+// readPolicy reads the stored Policy in its own transaction.
+func (handler *policyHandler) readPolicy() (*Policy, error) {
+	var policy *Policy
+	err := handler.db.View(func(tx *bolt.Tx) error {
+		var err error
+		policy, err = getPolicy(tx)
+		return err
+	})
+	return policy, err
+}
+
+// getPolicy reads the stored Policy, converting legacy payloads to the current version.
+func getPolicy(tx *bolt.Tx) (*Policy, error) {
+	bucket := tx.Bucket([]byte(policyBucket))
+	if bucket == nil {
+		err := errors.New("policy bucket doesn't exist")
+		log.Println("func", "getPolicy", "Failed to find bucket", "err:", err)
+		return nil, err
+	}
+
+	policy, err := Decode(bucket.Get([]byte("Policy")))
+	if err != nil {
+		log.Println("func", "getPolicy", "err:", err)
+		return nil, err
+	}
+	return policy, nil
+}
+
+// putPolicy stores a Policy.
+func putPolicy(tx *bolt.Tx, policy *Policy) error {
+	bucket := tx.Bucket([]byte(policyBucket))
+	if bucket == nil {
+		err := errors.New("policy bucket doesn't exist")
+		log.Println("func", "putPolicy", "Failed to find bucket", "err:", err)
+		return err
+	}
+
+	policyByteArray, err := json.Marshal(policy)
+	if err != nil {
+		log.Println("func", "putPolicy", "Failed to encode policy struct", "err:", err)
+		return err
+	}
+	log.Println("func", "putPolicy", "Updating Policy")
+	if err := bucket.Put([]byte("Policy"), policyByteArray); err != nil {
+		log.Println("func", "putPolicy", "Failed to put Policy", "err:", err)
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	historyBucket = "historyBucket"
+
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// Change is one entry of the policy history.
+type Change struct {
+	ID        uint64
+	Previous  *Policy
+	Current   *Policy
+	ChangedAt time.Time
+	// Requester identifies who made the change.
+	Requester string
+	// Reason is the free-form justification given with the change.
+	Reason string `json:",omitempty"`
+}
+
+// HistoryPage is a page of the policy history, oldest change first.
+type HistoryPage struct {
+	Items []Change
+	// Next is the cursor to pass as "after" to get the following page, empty on the last page.
+	Next string `json:",omitempty"`
+}
+
+// requester returns the identity of the client making a request: the authenticated user, else
+// the X-Requester header, else the client address.
+func requester(r *http.Request) string {
+	if user := UserFrom(r.Context()); user != nil {
+		return user.Name
+	}
+	if user := r.Header.Get("X-Requester"); user != "" {
+		return user
+	}
+	return r.RemoteAddr
+}
+
+// changeReason returns the reason given with a request, via the X-Change-Reason header or the reason query parameter.
+func changeReason(r *http.Request) string {
+	if reason := r.Header.Get("X-Change-Reason"); reason != "" {
+		return reason
+	}
+	return r.URL.Query().Get("reason")
+}
+
+// nextChangeID returns the ID of the next change, which is also the revision of the policy it sets.
+func nextChangeID(tx *bolt.Tx) (uint64, error) {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(historyBucket))
+	if err != nil {
+		log.Println("func", "nextChangeID", "Failed to create bucket", "err:", err)
+		return 0, err
+	}
+	return bucket.NextSequence()
+}
+
+// appendChange adds a change with an ID from nextChangeID to the history bucket.
+func appendChange(tx *bolt.Tx, change *Change) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(historyBucket))
+	if err != nil {
+		log.Println("func", "appendChange", "Failed to create bucket", "err:", err)
+		return err
+	}
+
+	changeByteArray, err := json.Marshal(change)
+	if err != nil {
+		log.Println("func", "appendChange", "Failed to encode change", "err:", err)
+		return err
+	}
+	return bucket.Put(historyKey(change.ID), changeByteArray)
+}
+
+// historyKey encodes an ID big-endian so that bolt keeps changes in order.
+func historyKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// History serves GET /policy/history. The query parameters are:
+//
+//	since, until: RFC 3339 bounds on ChangedAt, both inclusive
+//	limit: page size, 50 by default and at most 500
+//	after: the Next cursor of the previous page
+func (handler *policyHandler) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET, OPTIONS")
+		WriteProblem(w, r, http.StatusMethodNotAllowed, "Method "+r.Method+" is not supported.")
+		return
+	}
+
+	query := r.URL.Query()
+	var since, until time.Time
+	var err error
+	if value := query.Get("since"); value != "" {
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid since, expected an RFC 3339 time.")
+			return
+		}
+	}
+	if value := query.Get("until"); value != "" {
+		if until, err = time.Parse(time.RFC3339, value); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid until, expected an RFC 3339 time.")
+			return
+		}
+	}
+	limit := defaultHistoryLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxHistoryLimit {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid limit, expected a number between 1 and "+strconv.Itoa(maxHistoryLimit)+".")
+			return
+		}
+	}
+	var after uint64
+	if value := query.Get("after"); value != "" {
+		if after, err = strconv.ParseUint(value, 10, 64); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid after cursor.")
+			return
+		}
+	}
+
+	page := HistoryPage{Items: []Change{}}
+	err = handler.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(historyBucket))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Seek(historyKey(after + 1)); key != nil; key, value = cursor.Next() {
+			var change Change
+			if err := json.Unmarshal(value, &change); err != nil {
+				log.Println("func", "History", "Failed to decode change", "err:", err)
+				return err
+			}
+			if !since.IsZero() && change.ChangedAt.Before(since) {
+				continue
+			}
+			if !until.IsZero() && change.ChangedAt.After(until) {
+				// changes are appended in time order, nothing later can match
+				break
+			}
+			if len(page.Items) == limit {
+				page.Next = strconv.FormatUint(page.Items[len(page.Items)-1].ID, 10)
+				break
+			}
+			page.Items = append(page.Items, change)
+		}
+		return nil
+	})
+	if err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, "Failed to read the policy history.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		log.Println("func", "History", "Failed to write history", "err:", err)
+	}
+}
@@ -1,19 +1,49 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 
 	"github.com/kube-flux/kube-flux/policy"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 func main() {
-	var handler, err = policy.NewPolicyHandler()
+	maxReplicas := flag.Int("max-replicas", policy.DefaultMaxReplicas, "Highest replica count a policy factor may set")
+	kubeconfig := flag.String("kubeconfig", "", "Kubeconfig used by -token-review; the in-cluster config when empty")
+	var authOptions policy.AuthOptions
+	authOptions.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	var clientSet kubernetes.Interface
+	if authOptions.TokenReview {
+		config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+		if err != nil {
+			log.Fatalln("Failed to load Kubernetes config", "err:", err)
+		}
+		if clientSet, err = kubernetes.NewForConfig(config); err != nil {
+			log.Fatalln("Failed to create Kubernetes client", "err:", err)
+		}
+	}
+	auth, err := authOptions.NewAuth(clientSet)
+	if err != nil {
+		log.Fatalln("Failed to configure authentication", "err:", err)
+	}
+
+	handler, err := policy.NewPolicyHandler()
 	if err != nil {
 		log.Fatalln("Failed to initialize handler", "err:", err)
 	}
+	handler.Limits.MaxReplicas = int32(*maxReplicas)
+	mux := http.NewServeMux()
+	// only the documented paths are served, every other one answers 404
+	mux.Handle("/policy", handler)
+	mux.HandleFunc("/policy/schema", policy.SchemaHandler)
+	mux.HandleFunc("/policy/history", handler.History)
 	log.Println("Starting server")
-	if err := http.ListenAndServe(":9999", handler); err != nil {
+	if err := http.ListenAndServe(":9999", auth.Handler(mux)); err != nil {
 		log.Fatalln("Failed to start server", "err:", err)
 	}
 }
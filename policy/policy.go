@@ -1,5 +1,15 @@
 package policy
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIVersion is the version of the Policy model written by this package.
+// Payloads without a version are legacy v0 payloads, see Decode.
+const APIVersion = "v1"
+
 // Status represents Policy energy consumption status: Green, Brown, Black
 type Status string
 
@@ -9,8 +19,197 @@ const (
 	Black Status = "Black"
 )
 
+// Statuses lists every energy status, from the least to the most constrained.
+var Statuses = []Status{Green, Brown, Black}
+
+// statusAliases maps the statuses used by the controller and the UI before v1 onto Status.
+var statusAliases = map[string]Status{
+	"yellow": Brown,
+	"red":    Black,
+}
+
+// ParseStatus converts a case-insensitive status name, including the legacy Yellow and Red, into a Status.
+func ParseStatus(s string) (Status, error) {
+	s = strings.TrimSpace(s)
+	for _, status := range Statuses {
+		if strings.EqualFold(string(status), s) {
+			return status, nil
+		}
+	}
+	if status, ok := statusAliases[strings.ToLower(s)]; ok {
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown status %q", s)
+}
+
+// Class is the importance class of a workload: High, Medium, Low
+type Class string
+
+const (
+	High   Class = "High"
+	Medium Class = "Medium"
+	Low    Class = "Low"
+)
+
+// Classes lists every importance class, from the most to the least important.
+var Classes = []Class{High, Medium, Low}
+
+// classAliases maps the numeric "imp" annotation and other legacy names onto Class.
+var classAliases = map[string]Class{
+	"1":   High,
+	"2":   Medium,
+	"3":   Low,
+	"top": High,
+}
+
+// ParseClass converts a case-insensitive class name or a legacy numeric importance factor into a Class.
+func ParseClass(s string) (Class, error) {
+	s = strings.TrimSpace(s)
+	for _, class := range Classes {
+		if strings.EqualFold(string(class), s) {
+			return class, nil
+		}
+	}
+	if class, ok := classAliases[strings.ToLower(s)]; ok {
+		return class, nil
+	}
+	return "", fmt.Errorf("unknown importance class %q", s)
+}
+
+// Factors maps every energy status to the replica count of each importance class.
+type Factors map[Status]map[Class]int32
+
+// DefaultFactors returns the replica factors used when no factors were configured.
+func DefaultFactors() Factors {
+	return Factors{
+		Green: {High: 10, Medium: 10, Low: 10},
+		Brown: {High: 8, Medium: 8, Low: 8},
+		Black: {High: 3, Medium: 3, Low: 3},
+	}
+}
+
+// Replicas returns the replica count of a class in a status.
+func (f Factors) Replicas(status Status, class Class) (int32, bool) {
+	replicas, ok := f[status][class]
+	return replicas, ok
+}
+
+// Percentage sets the replicas of every workload of a class to a share of its baseline, the
+// replicas it had before the first energy-driven change, so that one policy fits workloads of
+// very different sizes.
+type Percentage struct {
+	// Percent of the baseline replicas, rounded up.
+	Percent int32
+	// Min and Max bound the replicas of every workload of the class.
+	Min *int32 `json:",omitempty"`
+	Max *int32 `json:",omitempty"`
+}
+
+// Replicas returns Percent of baseline, rounded up and bounded by Min, Max and limits.MaxReplicas.
+func (p Percentage) Replicas(baseline int32, limits Limits) int32 {
+	replicas := (int64(baseline)*int64(p.Percent) + 99) / 100
+	if p.Min != nil && replicas < int64(*p.Min) {
+		replicas = int64(*p.Min)
+	}
+	if p.Max != nil && replicas > int64(*p.Max) {
+		replicas = int64(*p.Max)
+	}
+	if limits.MaxReplicas > 0 && replicas > int64(limits.MaxReplicas) {
+		replicas = int64(limits.MaxReplicas)
+	}
+	return int32(replicas)
+}
+
+// String formats the percentage like "80% min 1 max 5".
+func (p Percentage) String() string {
+	s := fmt.Sprintf("%d%%", p.Percent)
+	if p.Min != nil {
+		s += fmt.Sprintf(" min %d", *p.Min)
+	}
+	if p.Max != nil {
+		s += fmt.Sprintf(" max %d", *p.Max)
+	}
+	return s
+}
+
+// Percentages maps energy statuses to the Percentage of importance classes. A class with a
+// percentage in a status ignores its replica factor there.
+type Percentages map[Status]map[Class]Percentage
+
+// Percentage returns the percentage of a class in a status.
+func (p Percentages) Percentage(status Status, class Class) (Percentage, bool) {
+	percentage, ok := p[status][class]
+	return percentage, ok
+}
+
 // Policy defines the object that maintains energy related status
 type Policy struct {
-	Status    Status
-	UpdatedAt string
+	APIVersion string
+	Status     Status
+	// Factor keeps its v0 name so that existing clients reading it keep working.
+	Factor Factors `json:",omitempty"`
+	// Percentages take precedence over Factor for the classes they define.
+	Percentages Percentages `json:",omitempty"`
+	UpdatedAt   time.Time
+	// Revision increases with every change. It is assigned by the server, the value of a request is ignored.
+	Revision uint64 `json:",omitempty"`
+}
+
+// Default returns a Green policy with the default factors.
+func Default() *Policy {
+	return &Policy{
+		APIVersion: APIVersion,
+		Status:     Green,
+		Factor:     DefaultFactors(),
+		UpdatedAt:  time.Now(),
+	}
+}
+
+// DeepCopy returns a copy of p that shares no maps with it.
+func (p *Policy) DeepCopy() *Policy {
+	if p == nil {
+		return nil
+	}
+	out := *p
+	out.Factor = p.Factor.DeepCopy()
+	out.Percentages = p.Percentages.DeepCopy()
+	return &out
+}
+
+// DeepCopy returns a copy of f that shares no maps with it.
+func (f Factors) DeepCopy() Factors {
+	if f == nil {
+		return nil
+	}
+	out := make(Factors, len(f))
+	for status, classes := range f {
+		out[status] = make(map[Class]int32, len(classes))
+		for class, replicas := range classes {
+			out[status][class] = replicas
+		}
+	}
+	return out
+}
+
+// DeepCopy returns a copy of p that shares no maps or pointers with it.
+func (p Percentages) DeepCopy() Percentages {
+	if p == nil {
+		return nil
+	}
+	out := make(Percentages, len(p))
+	for status, classes := range p {
+		out[status] = make(map[Class]Percentage, len(classes))
+		for class, percentage := range classes {
+			if percentage.Min != nil {
+				min := *percentage.Min
+				percentage.Min = &min
+			}
+			if percentage.Max != nil {
+				max := *percentage.Max
+				percentage.Max = &max
+			}
+			out[status][class] = percentage
+		}
+	}
+	return out
 }
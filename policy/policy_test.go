@@ -0,0 +1,38 @@
+package policy
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentageReplicas(t *testing.T) {
+	tests := []struct {
+		name       string
+		percentage Percentage
+		baseline   int32
+		limits     Limits
+		want       int32
+	}{
+		{name: "exact", percentage: Percentage{Percent: 50}, baseline: 4, want: 2},
+		{name: "rounded up", percentage: Percentage{Percent: 50}, baseline: 3, want: 2},
+		{name: "rounded up from a fraction", percentage: Percentage{Percent: 1}, baseline: 1, want: 1},
+		{name: "whole", percentage: Percentage{Percent: 100}, baseline: 7, want: 7},
+		{name: "zero", percentage: Percentage{Percent: 0}, baseline: 7, want: 0},
+		{name: "no baseline", percentage: Percentage{Percent: 50}, baseline: 0, want: 0},
+		{name: "min", percentage: Percentage{Percent: 10, Min: int32Ptr(2)}, baseline: 5, want: 2},
+		{name: "max", percentage: Percentage{Percent: 80, Max: int32Ptr(3)}, baseline: 10, want: 3},
+		{name: "within bounds", percentage: Percentage{Percent: 50, Min: int32Ptr(1), Max: int32Ptr(8)}, baseline: 10, want: 5},
+		{name: "limit", percentage: Percentage{Percent: 100}, baseline: 40, limits: Limits{MaxReplicas: 20}, want: 20},
+		{name: "min above limit", percentage: Percentage{Percent: 10, Min: int32Ptr(30)}, baseline: 10, limits: Limits{MaxReplicas: 20}, want: 20},
+		{name: "no limit", percentage: Percentage{Percent: 100}, baseline: 40, want: 40},
+		{name: "large baseline", percentage: Percentage{Percent: 100}, baseline: math.MaxInt32, want: math.MaxInt32},
+		{name: "large baseline limited", percentage: Percentage{Percent: 75}, baseline: math.MaxInt32, limits: DefaultLimits, want: DefaultMaxReplicas},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.percentage.Replicas(test.baseline, test.limits); got != test.want {
+				t.Errorf("%v of %d = %d, want %d", test.percentage, test.baseline, got, test.want)
+			}
+		})
+	}
+}
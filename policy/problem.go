@@ -0,0 +1,58 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// WriteProblem writes a problem details response with the given HTTP status code.
+func WriteProblem(w http.ResponseWriter, r *http.Request, code int, detail string) {
+	writeProblem(w, &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+// WriteValidationProblem writes a 422 response for err, listing the invalid fields
+// when err is a *ValidationError.
+func WriteValidationProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := &Problem{
+		Type:     "about:blank",
+		Title:    "Invalid policy",
+		Status:   http.StatusUnprocessableEntity,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		problem.Detail = "The policy failed validation."
+		problem.InvalidParams = validationErr.Params
+	}
+	writeProblem(w, problem)
+}
+
+func writeProblem(w http.ResponseWriter, problem *Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		log.Println("func", "writeProblem", "Failed to write problem", "err:", err)
+	}
+}
@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"log"
+	"net/http"
+)
+
+// Schema is the JSON schema of the v1 Policy payload.
+const Schema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/kube-flux/kube-flux/policy/v1",
+  "title": "Policy",
+  "type": "object",
+  "required": ["APIVersion", "Status"],
+  "properties": {
+    "APIVersion": {
+      "const": "v1"
+    },
+    "Status": {
+      "enum": ["Green", "Brown", "Black"]
+    },
+    "Factor": {
+      "description": "Replica count of each importance class, per energy status.",
+      "type": "object",
+      "propertyNames": {"enum": ["Green", "Brown", "Black"]},
+      "additionalProperties": {
+        "type": "object",
+        "propertyNames": {"enum": ["High", "Medium", "Low"]},
+        "additionalProperties": {"type": "integer", "minimum": 0, "maximum": 2147483647}
+      }
+    },
+    "Percentages": {
+      "description": "Share of the baseline replicas of each importance class, per energy status; takes precedence over Factor.",
+      "type": "object",
+      "propertyNames": {"enum": ["Green", "Brown", "Black"]},
+      "additionalProperties": {
+        "type": "object",
+        "propertyNames": {"enum": ["High", "Medium", "Low"]},
+        "additionalProperties": {
+          "type": "object",
+          "required": ["Percent"],
+          "properties": {
+            "Percent": {"type": "integer", "minimum": 0, "maximum": 100},
+            "Min": {"type": "integer", "minimum": 0},
+            "Max": {"type": "integer", "minimum": 0}
+          },
+          "additionalProperties": false
+        }
+      }
+    },
+    "UpdatedAt": {
+      "type": "string",
+      "format": "date-time"
+    },
+    "Revision": {
+      "description": "Increases with every change, assigned by the server.",
+      "type": "integer",
+      "minimum": 0
+    }
+  }
+}
+`
+
+// SchemaHandler serves the JSON schema of the Policy payload.
+func SchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	if _, err := w.Write([]byte(Schema)); err != nil {
+		log.Println("func", "SchemaHandler", "Failed to write schema", "err:", err)
+	}
+}
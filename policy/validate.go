@@ -0,0 +1,173 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxReplicas is the default cap on the replica count of a class.
+const DefaultMaxReplicas = 100
+
+// MaxPercent is the highest Percent of a Percentage: energy statuses only ever shrink workloads.
+const MaxPercent = 100
+
+// Limits bound the values a Policy may carry.
+type Limits struct {
+	// MaxReplicas is the highest replica count a factor may set.
+	MaxReplicas int32
+}
+
+// DefaultLimits are the limits used when none were configured.
+var DefaultLimits = Limits{MaxReplicas: DefaultMaxReplicas}
+
+// InvalidParam describes why one field of a Policy was rejected.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError lists every invalid field of a Policy.
+type ValidationError struct {
+	Params []InvalidParam
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Params))
+	for _, param := range e.Params {
+		reasons = append(reasons, param.Name+": "+param.Reason)
+	}
+	return "invalid policy: " + strings.Join(reasons, "; ")
+}
+
+// Validate checks that a Policy only uses known statuses and classes, that its factors
+// define every class of every status with a replica count between 0 and limits.MaxReplicas,
+// and that its percentages are between 0 and MaxPercent with bounds between 0 and limits.MaxReplicas.
+// It returns a *ValidationError listing every problem found.
+func (p *Policy) Validate(limits Limits) error {
+	var params []InvalidParam
+	if !p.Status.Known() {
+		params = append(params, InvalidParam{Name: "Status", Reason: fmt.Sprintf("unknown status %q, must be one of %s", p.Status, joinStatuses(Statuses))})
+	}
+	params = append(params, p.Factor.validate(limits)...)
+	params = append(params, p.Percentages.validate(limits)...)
+	if len(params) != 0 {
+		return &ValidationError{Params: params}
+	}
+	return nil
+}
+
+// Validate checks factors the same way Policy.Validate does.
+func (f Factors) Validate(limits Limits) error {
+	if params := f.validate(limits); len(params) != 0 {
+		return &ValidationError{Params: params}
+	}
+	return nil
+}
+
+func (f Factors) validate(limits Limits) []InvalidParam {
+	// no factors means the receiver keeps its own
+	if len(f) == 0 {
+		return nil
+	}
+
+	var params []InvalidParam
+	for status := range f {
+		if !status.Known() {
+			params = append(params, InvalidParam{Name: "Factor." + string(status), Reason: "unknown status"})
+		}
+	}
+	for _, status := range Statuses {
+		classes, ok := f[status]
+		if !ok {
+			params = append(params, InvalidParam{Name: "Factor." + string(status), Reason: "missing status"})
+			continue
+		}
+		for class := range classes {
+			if !class.Known() {
+				params = append(params, InvalidParam{Name: "Factor." + string(status) + "." + string(class), Reason: "unknown importance class"})
+			}
+		}
+		for _, class := range Classes {
+			name := "Factor." + string(status) + "." + string(class)
+			replicas, ok := classes[class]
+			switch {
+			case !ok:
+				params = append(params, InvalidParam{Name: name, Reason: "missing importance class"})
+			case replicas < 0:
+				params = append(params, InvalidParam{Name: name, Reason: "replicas must not be negative"})
+			case limits.MaxReplicas > 0 && replicas > limits.MaxReplicas:
+				params = append(params, InvalidParam{Name: name, Reason: fmt.Sprintf("replicas must not exceed %d", limits.MaxReplicas)})
+			}
+		}
+	}
+	return params
+}
+
+// Validate checks percentages the same way Policy.Validate does.
+func (p Percentages) Validate(limits Limits) error {
+	if params := p.validate(limits); len(params) != 0 {
+		return &ValidationError{Params: params}
+	}
+	return nil
+}
+
+func (p Percentages) validate(limits Limits) []InvalidParam {
+	// unlike factors, percentages may leave out statuses and classes
+	var params []InvalidParam
+	for status, classes := range p {
+		if !status.Known() {
+			params = append(params, InvalidParam{Name: "Percentages." + string(status), Reason: "unknown status"})
+			continue
+		}
+		for class, percentage := range classes {
+			name := "Percentages." + string(status) + "." + string(class)
+			switch {
+			case !class.Known():
+				params = append(params, InvalidParam{Name: name, Reason: "unknown importance class"})
+			case percentage.Percent < 0:
+				params = append(params, InvalidParam{Name: name + ".Percent", Reason: "percent must not be negative"})
+			case percentage.Percent > MaxPercent:
+				params = append(params, InvalidParam{Name: name + ".Percent", Reason: fmt.Sprintf("percent must not exceed %d", MaxPercent)})
+			case percentage.Min != nil && *percentage.Min < 0:
+				params = append(params, InvalidParam{Name: name + ".Min", Reason: "replicas must not be negative"})
+			case percentage.Max != nil && percentage.Min != nil && *percentage.Max < *percentage.Min:
+				params = append(params, InvalidParam{Name: name + ".Max", Reason: "max must not be lower than min"})
+			case percentage.Max != nil && *percentage.Max < 0:
+				params = append(params, InvalidParam{Name: name + ".Max", Reason: "replicas must not be negative"})
+			case limits.MaxReplicas > 0 && percentage.Min != nil && *percentage.Min > limits.MaxReplicas:
+				params = append(params, InvalidParam{Name: name + ".Min", Reason: fmt.Sprintf("replicas must not exceed %d", limits.MaxReplicas)})
+			case limits.MaxReplicas > 0 && percentage.Max != nil && *percentage.Max > limits.MaxReplicas:
+				params = append(params, InvalidParam{Name: name + ".Max", Reason: fmt.Sprintf("replicas must not exceed %d", limits.MaxReplicas)})
+			}
+		}
+	}
+	return params
+}
+
+// Known reports whether s is one of Statuses.
+func (s Status) Known() bool {
+	for _, status := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Known reports whether c is one of Classes.
+func (c Class) Known() bool {
+	for _, class := range Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func joinStatuses(statuses []Status) string {
+	names := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		names = append(names, string(status))
+	}
+	return strings.Join(names, ", ")
+}
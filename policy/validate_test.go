@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// paramNames returns the names of the invalid params of a *ValidationError, nil for no error.
+func paramNames(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got %T %v, want a *ValidationError", err, err)
+	}
+	names := make([]string, 0, len(validationErr.Params))
+	for _, param := range validationErr.Params {
+		names = append(names, param.Name)
+	}
+	return names
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		// want are the names of the invalid params, in order
+		want []string
+	}{
+		{name: "default", policy: *Default()},
+		{name: "status only", policy: Policy{Status: Black}},
+		{name: "unknown status", policy: Policy{Status: "Yellow"}, want: []string{"Status"}},
+		{
+			name: "missing status",
+			policy: Policy{Status: Green, Factor: Factors{
+				Green: {High: 1, Medium: 1, Low: 1},
+				Brown: {High: 1, Medium: 1, Low: 1},
+			}},
+			want: []string{"Factor.Black"},
+		},
+		{
+			name: "missing class",
+			policy: Policy{Status: Green, Factor: Factors{
+				Green: {High: 1, Medium: 1},
+				Brown: {High: 1, Medium: 1, Low: 1},
+				Black: {High: 1, Medium: 1, Low: 1},
+			}},
+			want: []string{"Factor.Green.Low"},
+		},
+		{
+			name: "unknown status and class",
+			policy: Policy{Status: Green, Factor: Factors{
+				Green:    {High: 1, Medium: 1, Low: 1, "Top": 1},
+				Brown:    {High: 1, Medium: 1, Low: 1},
+				Black:    {High: 1, Medium: 1, Low: 1},
+				"Yellow": {High: 1},
+			}},
+			want: []string{"Factor.Yellow", "Factor.Green.Top"},
+		},
+		{
+			name: "negative and too many replicas",
+			policy: Policy{Status: Green, Factor: Factors{
+				Green: {High: DefaultMaxReplicas + 1, Medium: 1, Low: 1},
+				Brown: {High: 1, Medium: -1, Low: 1},
+				Black: {High: 0, Medium: 0, Low: 0},
+			}},
+			want: []string{"Factor.Green.High", "Factor.Brown.Medium"},
+		},
+		{
+			name:   "every problem",
+			policy: Policy{Status: "Red", Factor: Factors{Green: {High: -1}}},
+			want:   []string{"Status", "Factor.Green.High", "Factor.Green.Medium", "Factor.Green.Low", "Factor.Brown", "Factor.Black"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := paramNames(t, test.policy.Validate(DefaultLimits))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got invalid params %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFactorsValidateLimits(t *testing.T) {
+	factors := DefaultFactors()
+	if err := factors.Validate(Limits{MaxReplicas: 10}); err != nil {
+		t.Errorf("got %v, want the default factors to fit 10 replicas", err)
+	}
+	got := paramNames(t, factors.Validate(Limits{MaxReplicas: 8}))
+	want := []string{"Factor.Green.High", "Factor.Green.Medium", "Factor.Green.Low"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got invalid params %v, want %v", got, want)
+	}
+	// no limit
+	if err := factors.Validate(Limits{}); err != nil {
+		t.Errorf("got %v without a limit", err)
+	}
+}
+
+func TestPercentagesValidate(t *testing.T) {
+	limits := Limits{MaxReplicas: 10}
+	tests := []struct {
+		name       string
+		percentage Percentage
+		want       []string
+	}{
+		{name: "valid", percentage: Percentage{Percent: 50, Min: int32Ptr(1), Max: int32Ptr(10)}},
+		{name: "zero", percentage: Percentage{Percent: 0}},
+		{name: "whole", percentage: Percentage{Percent: MaxPercent}},
+		{name: "negative percent", percentage: Percentage{Percent: -1}, want: []string{"Percentages.Black.Low.Percent"}},
+		{name: "percent above 100", percentage: Percentage{Percent: MaxPercent + 1}, want: []string{"Percentages.Black.Low.Percent"}},
+		{name: "negative min", percentage: Percentage{Percent: 50, Min: int32Ptr(-1)}, want: []string{"Percentages.Black.Low.Min"}},
+		{name: "negative max", percentage: Percentage{Percent: 50, Max: int32Ptr(-1)}, want: []string{"Percentages.Black.Low.Max"}},
+		{name: "max below min", percentage: Percentage{Percent: 50, Min: int32Ptr(3), Max: int32Ptr(2)}, want: []string{"Percentages.Black.Low.Max"}},
+		{name: "min above limit", percentage: Percentage{Percent: 50, Min: int32Ptr(11)}, want: []string{"Percentages.Black.Low.Min"}},
+		{name: "max above limit", percentage: Percentage{Percent: 50, Max: int32Ptr(11)}, want: []string{"Percentages.Black.Low.Max"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			percentages := Percentages{Black: {Low: test.percentage}}
+			got := paramNames(t, percentages.Validate(limits))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got invalid params %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	unknown := Percentages{"Red": {Low: {Percent: 50}}, Brown: {"Top": {Percent: 50}}}
+	got := paramNames(t, unknown.Validate(limits))
+	sort.Strings(got)
+	want := []string{"Percentages.Brown.Top", "Percentages.Red"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got invalid params %v, want %v", got, want)
+	}
+}
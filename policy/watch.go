@@ -0,0 +1,210 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWatchBacklog is the number of events kept to resume watches.
+	defaultWatchBacklog = 100
+	// subscriberBuffer is the number of events a slow watcher may lag behind before it is dropped.
+	subscriberBuffer = 16
+	// heartbeatInterval is how often an idle watch stream gets a comment to keep proxies from closing it.
+	heartbeatInterval = 30 * time.Second
+)
+
+// errResourceVersionGone is returned when a watch resumes from a resource version no longer kept.
+var errResourceVersionGone = errors.New("resource version is too old")
+
+// Event is a policy change sent to watchers.
+type Event struct {
+	// ResourceVersion increases with every change, watches resume after it.
+	ResourceVersion uint64
+	Policy          *Policy
+}
+
+// Broadcaster fans policy changes out to watchers and keeps the latest ones to resume watches.
+type Broadcaster struct {
+	mu              sync.Mutex
+	resourceVersion uint64
+	backlog         []Event
+	backlogSize     int
+	subscribers     map[chan Event]struct{}
+}
+
+// NewBroadcaster creates a Broadcaster whose current resource version is resourceVersion.
+func NewBroadcaster(resourceVersion uint64) *Broadcaster {
+	return &Broadcaster{
+		resourceVersion: resourceVersion,
+		backlogSize:     defaultWatchBacklog,
+		subscribers:     make(map[chan Event]struct{}),
+	}
+}
+
+// ResourceVersion returns the resource version of the latest change.
+func (b *Broadcaster) ResourceVersion() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.resourceVersion
+}
+
+// Publish sends a change to every watcher and keeps a copy of p. A change older than the
+// latest published one is dropped, the watchers already have a newer policy.
+func (b *Broadcaster) Publish(resourceVersion uint64, p *Policy) {
+	event := Event{ResourceVersion: resourceVersion, Policy: p.DeepCopy()}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if resourceVersion <= b.resourceVersion {
+		return
+	}
+	b.resourceVersion = resourceVersion
+	b.backlog = append(b.backlog, event)
+	if len(b.backlog) > b.backlogSize {
+		b.backlog = b.backlog[len(b.backlog)-b.backlogSize:]
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// the watcher can't keep up, it resumes from its last event after reconnecting
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a watcher that already saw every change up to since and returns the
+// changes it missed, or errResourceVersionGone if they are no longer kept.
+func (b *Broadcaster) subscribe(since uint64) ([]Event, chan Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if since < b.resourceVersion {
+		if len(b.backlog) == 0 || b.backlog[0].ResourceVersion > since+1 {
+			return nil, nil, errResourceVersionGone
+		}
+		for _, event := range b.backlog {
+			if event.ResourceVersion > since {
+				missed = append(missed, event)
+			}
+		}
+	}
+	ch := make(chan Event, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	return missed, ch, nil
+}
+
+// unsubscribe removes a watcher unless Publish already dropped it.
+func (b *Broadcaster) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// IsWatch reports whether a GET asks to watch the policy, i.e. carries watch=true.
+func IsWatch(r *http.Request) bool {
+	watch, _ := strconv.ParseBool(r.URL.Query().Get("watch"))
+	return watch
+}
+
+// ServeWatch streams policy changes as Server-Sent Events, each event carrying its resource
+// version as id. A watch resumes after the resourceVersion query parameter or the Last-Event-ID
+// header; without either, the current policy returned by current is sent first.
+// Resuming from a resource version no longer kept answers 410 Gone.
+func ServeWatch(w http.ResponseWriter, r *http.Request, b *Broadcaster, current func() (*Policy, error)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteProblem(w, r, http.StatusInternalServerError, "Streaming is not supported.")
+		return
+	}
+
+	resume := r.URL.Query().Get("resourceVersion")
+	if resume == "" {
+		resume = r.Header.Get("Last-Event-ID")
+	}
+
+	var missed []Event
+	var ch chan Event
+	var err error
+	if resume != "" {
+		since, err := strconv.ParseUint(resume, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid resourceVersion.")
+			return
+		}
+		missed, ch, err = b.subscribe(since)
+		if err == errResourceVersionGone {
+			WriteProblem(w, r, http.StatusGone, fmt.Sprintf("Resource version %d is too old, get the policy and watch again.", since))
+			return
+		}
+	} else {
+		// subscribe before reading the current policy so that no change is lost in between
+		resourceVersion := b.ResourceVersion()
+		missed, ch, _ = b.subscribe(resourceVersion)
+		policy, err := current()
+		if err != nil {
+			b.unsubscribe(ch)
+			WriteProblem(w, r, http.StatusInternalServerError, "Failed to read the policy.")
+			return
+		}
+		missed = append([]Event{{ResourceVersion: resourceVersion, Policy: policy}}, missed...)
+	}
+	defer b.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		if err = writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				log.Println("func", "ServeWatch", "Dropped slow watcher", r.RemoteAddr)
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes one Server-Sent Event.
+func writeEvent(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event.Policy)
+	if err != nil {
+		log.Println("func", "writeEvent", "Failed to encode policy", "err:", err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: policy\ndata: %s\n\n", event.ResourceVersion, data)
+	return err
+}
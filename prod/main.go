@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/kube-flux/kube-flux/scaling"
 	"io/ioutil"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -86,9 +87,11 @@ func changeReplica(clientSet *kubernetes.Clientset) {
 	fmt.Printf("Number of replica-set deployed currently : %d\n", *(nginx.Spec.Replicas))
 	var newReplica int32 = 2
 	fmt.Printf("Changing the # of replica-set to %d\n", newReplica)
-	*(nginx.Spec.Replicas) = newReplica
-	_, _ = clientSet.AppsV1().Deployments(namespace).Update(context.TODO(), &nginx, metav1.UpdateOptions{})
-	fmt.Printf("Number of replica-set deployed after change : %d\n", *(nginx.Spec.Replicas))
+	if _, err := scaling.SetReplicas(context.TODO(), clientSet, namespace, nginx.GetName(), newReplica); err != nil {
+		fmt.Printf("Failed to change the # of replica-set: %v\n", err)
+		return
+	}
+	fmt.Printf("Number of replica-set deployed after change : %d\n", newReplica)
 
 }
 
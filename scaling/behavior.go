@@ -0,0 +1,146 @@
+package scaling
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Behavior limits how fast the replicas of a workload change, like the behavior of a
+// HorizontalPodAutoscaler, so that usage oscillating around a band doesn't make replicas flap.
+type Behavior struct {
+	// ScaleUpStabilization scales up to the lowest target recommended over the window.
+	ScaleUpStabilization time.Duration
+	// ScaleDownStabilization scales down to the highest target recommended over the window.
+	ScaleDownStabilization time.Duration
+	// MaxScaleUpStep and MaxScaleDownStep bound the replicas added or removed by one change, 0 for no bound.
+	MaxScaleUpStep   int
+	MaxScaleDownStep int
+	// Cooldown is how long the replicas of a workload are left alone after a change.
+	Cooldown time.Duration
+}
+
+// AddFlags registers the behavior on a flag set, with defaults stabilizing scale-downs over 5 minutes like an HPA.
+func (b *Behavior) AddFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&b.ScaleUpStabilization, "scale-up-stabilization", 0, "Scale up to the lowest target recommended over this window")
+	fs.DurationVar(&b.ScaleDownStabilization, "scale-down-stabilization", 5*time.Minute, "Scale down to the highest target recommended over this window")
+	fs.IntVar(&b.MaxScaleUpStep, "max-scale-up-step", 0, "Most replicas added to a deployment by one change, 0 for no limit")
+	fs.IntVar(&b.MaxScaleDownStep, "max-scale-down-step", 0, "Most replicas removed from a deployment by one change, 0 for no limit")
+	fs.DurationVar(&b.Cooldown, "scale-cooldown", 30*time.Second, "How long the replicas of a deployment are left alone after a change")
+}
+
+// Validate checks that no duration or step is negative.
+func (b *Behavior) Validate() error {
+	if b.ScaleUpStabilization < 0 || b.ScaleDownStabilization < 0 || b.Cooldown < 0 {
+		return fmt.Errorf("stabilization windows and cooldown must not be negative")
+	}
+	if b.MaxScaleUpStep < 0 || b.MaxScaleDownStep < 0 {
+		return fmt.Errorf("max steps must not be negative")
+	}
+	return nil
+}
+
+type recommendation struct {
+	replicas int32
+	at       time.Time
+}
+
+// workload is the scaling history of one workload.
+type workload struct {
+	recommendations []recommendation
+	lastChange      time.Time
+}
+
+// Stabilizer applies a Behavior to the replica targets of workloads. It is safe for concurrent use.
+type Stabilizer struct {
+	behavior Behavior
+	// now is the clock of the windows and cooldown, time.Now outside tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	workloads map[string]*workload
+}
+
+// NewStabilizer creates a Stabilizer applying behavior.
+func NewStabilizer(behavior Behavior) *Stabilizer {
+	return &Stabilizer{behavior: behavior, now: time.Now, workloads: make(map[string]*workload)}
+}
+
+// Stabilize records desired as the recommended replicas of the workload identified by key and
+// returns the replicas to set now, along with why they differ from desired.
+func (s *Stabilizer) Stabilize(key string, current, desired int32) (int32, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	w, ok := s.workloads[key]
+	if !ok {
+		// the current replicas count as a recommendation, so that the first change is stabilized too
+		w = &workload{recommendations: []recommendation{{replicas: current, at: now}}}
+		s.workloads[key] = w
+	}
+
+	// keep the recommendations of the longest window
+	window := s.behavior.ScaleUpStabilization
+	if s.behavior.ScaleDownStabilization > window {
+		window = s.behavior.ScaleDownStabilization
+	}
+	kept := w.recommendations[:0]
+	for _, r := range w.recommendations {
+		if now.Sub(r.at) < window {
+			kept = append(kept, r)
+		}
+	}
+	w.recommendations = append(kept, recommendation{replicas: desired, at: now})
+
+	upLimit, downLimit := desired, desired
+	for _, r := range w.recommendations {
+		age := now.Sub(r.at)
+		if age < s.behavior.ScaleUpStabilization && r.replicas < upLimit {
+			upLimit = r.replicas
+		}
+		if age < s.behavior.ScaleDownStabilization && r.replicas > downLimit {
+			downLimit = r.replicas
+		}
+	}
+
+	replicas, reason := current, ""
+	if replicas < upLimit {
+		replicas = upLimit
+	}
+	if replicas > downLimit {
+		replicas = downLimit
+	}
+	if replicas != desired {
+		reason = "stabilization window"
+	}
+
+	if step := int32(s.behavior.MaxScaleUpStep); step > 0 && replicas > current+step {
+		replicas, reason = current+step, "max scale-up step"
+	}
+	if step := int32(s.behavior.MaxScaleDownStep); step > 0 && replicas < current-step {
+		replicas, reason = current-step, "max scale-down step"
+	}
+
+	if replicas != current && !w.lastChange.IsZero() && now.Sub(w.lastChange) < s.behavior.Cooldown {
+		remaining := s.behavior.Cooldown - now.Sub(w.lastChange)
+		return current, fmt.Sprintf("cooldown, %s left", remaining.Round(time.Second))
+	}
+	return replicas, reason
+}
+
+// Changed starts the cooldown of a workload whose replicas were just changed.
+func (s *Stabilizer) Changed(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.workloads[key]; ok {
+		w.lastChange = s.now()
+	}
+}
+
+// Forget drops the history of a workload, e.g. when it was deleted.
+func (s *Stabilizer) Forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.workloads, key)
+}
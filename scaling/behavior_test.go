@@ -0,0 +1,134 @@
+package scaling
+
+import (
+	"testing"
+	"time"
+)
+
+// step is one call of Stabilize after advancing the clock by after.
+type step struct {
+	after            time.Duration
+	current, desired int32
+	want             int32
+	reason           string
+	// changed starts the cooldown after the call
+	changed bool
+}
+
+func TestStabilizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior Behavior
+		steps    []step
+	}{
+		{
+			name:     "no behavior",
+			behavior: Behavior{},
+			steps: []step{
+				{current: 5, desired: 2, want: 2},
+				{current: 2, desired: 6, want: 6},
+			},
+		},
+		{
+			name:     "scale-down window",
+			behavior: Behavior{ScaleDownStabilization: 5 * time.Minute},
+			steps: []step{
+				{current: 5, desired: 2, want: 5, reason: "stabilization window"},
+				{after: 3 * time.Minute, current: 5, desired: 2, want: 5, reason: "stabilization window"},
+				{after: 2 * time.Minute, current: 5, desired: 2, want: 2},
+			},
+		},
+		{
+			name:     "scale-down to the highest recommendation",
+			behavior: Behavior{ScaleDownStabilization: 5 * time.Minute},
+			steps: []step{
+				{current: 2, desired: 8, want: 8},
+				{after: time.Minute, current: 8, desired: 4, want: 8, reason: "stabilization window"},
+				{after: 4 * time.Minute, current: 8, desired: 3, want: 4, reason: "stabilization window"},
+			},
+		},
+		{
+			name:     "scale-up window",
+			behavior: Behavior{ScaleUpStabilization: time.Minute},
+			steps: []step{
+				{current: 2, desired: 5, want: 2, reason: "stabilization window"},
+				{after: 30 * time.Second, current: 2, desired: 5, want: 2, reason: "stabilization window"},
+				{after: 30 * time.Second, current: 2, desired: 5, want: 5},
+				{current: 5, desired: 1, want: 1},
+			},
+		},
+		{
+			name:     "max steps",
+			behavior: Behavior{MaxScaleUpStep: 2, MaxScaleDownStep: 1},
+			steps: []step{
+				{current: 1, desired: 6, want: 3, reason: "max scale-up step"},
+				{current: 3, desired: 6, want: 5, reason: "max scale-up step"},
+				{current: 5, desired: 6, want: 6},
+				{current: 6, desired: 2, want: 5, reason: "max scale-down step"},
+			},
+		},
+		{
+			name:     "cooldown",
+			behavior: Behavior{Cooldown: 30 * time.Second},
+			steps: []step{
+				{current: 2, desired: 4, want: 4, changed: true},
+				{after: 10 * time.Second, current: 4, desired: 6, want: 4, reason: "cooldown, 20s left"},
+				{current: 4, desired: 4, want: 4},
+				{after: 20 * time.Second, current: 4, desired: 6, want: 6},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Date(2020, 11, 2, 15, 0, 0, 0, time.UTC)
+			s := NewStabilizer(test.behavior)
+			s.now = func() time.Time { return now }
+			for i, step := range test.steps {
+				now = now.Add(step.after)
+				got, reason := s.Stabilize("shop/web", step.current, step.desired)
+				if got != step.want || reason != step.reason {
+					t.Errorf("step %d: got %d replicas (%q), want %d (%q)", i, got, reason, step.want, step.reason)
+				}
+				if step.changed {
+					s.Changed("shop/web")
+				}
+			}
+		})
+	}
+}
+
+func TestStabilizerForget(t *testing.T) {
+	now := time.Date(2020, 11, 2, 15, 0, 0, 0, time.UTC)
+	s := NewStabilizer(Behavior{ScaleDownStabilization: 5 * time.Minute, Cooldown: time.Minute})
+	s.now = func() time.Time { return now }
+	if got, _ := s.Stabilize("shop/web", 5, 2); got != 5 {
+		t.Fatalf("got %d replicas, want the scale-down to be stabilized", got)
+	}
+	// other workloads have their own history
+	if got, _ := s.Stabilize("shop/api", 2, 2); got != 2 {
+		t.Errorf("got %d replicas of another workload, want 2", got)
+	}
+	s.Forget("shop/web")
+	if got, _ := s.Stabilize("shop/web", 2, 2); got != 2 {
+		t.Errorf("got %d replicas after Forget, want 2", got)
+	}
+}
+
+func TestBehaviorValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior Behavior
+		wantErr  bool
+	}{
+		{name: "zero", behavior: Behavior{}},
+		{name: "valid", behavior: Behavior{ScaleDownStabilization: time.Minute, MaxScaleUpStep: 2, Cooldown: time.Second}},
+		{name: "negative window", behavior: Behavior{ScaleUpStabilization: -time.Second}, wantErr: true},
+		{name: "negative cooldown", behavior: Behavior{Cooldown: -time.Second}, wantErr: true},
+		{name: "negative step", behavior: Behavior{MaxScaleDownStep: -1}, wantErr: true},
+	}
+	for _, test := range tests {
+		if err := test.behavior.Validate(); (err != nil) != test.wantErr {
+			t.Errorf("%s: got %v, want error %t", test.name, err, test.wantErr)
+		}
+	}
+}
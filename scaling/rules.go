@@ -0,0 +1,237 @@
+// Package scaling maps the usage of importance classes to the replica targets of every energy status.
+package scaling
+
+import (
+	"fmt"
+
+	"github.com/kube-flux/kube-flux/metrics"
+	"github.com/kube-flux/kube-flux/policy"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ActionAdd only scales up to the target.
+	ActionAdd = "add"
+	// ActionSubtract only scales down to the target.
+	ActionSubtract = "subtract"
+)
+
+// Band maps a usage range of an importance class to a replica target. A band without any
+// bound matches every usage.
+type Band struct {
+	// CPUAbove and CPUBelow bound the average CPU usage of a pod, e.g. 250m.
+	CPUAbove *resource.Quantity `json:"cpuAbove,omitempty"`
+	CPUBelow *resource.Quantity `json:"cpuBelow,omitempty"`
+	// MemoryAbove and MemoryBelow bound the average memory usage of a pod, e.g. 256Mi.
+	MemoryAbove *resource.Quantity `json:"memoryAbove,omitempty"`
+	MemoryBelow *resource.Quantity `json:"memoryBelow,omitempty"`
+	// Replicas is the target of the band.
+	Replicas int32 `json:"replicas"`
+	// Action is the allowed direction: "add" only scales up, "subtract" only scales down,
+	// and empty scales both ways.
+	Action string `json:"action,omitempty"`
+}
+
+// Rule is the replica target of an importance class in an energy status.
+type Rule struct {
+	Status string `json:"status"`
+	Class  string `json:"class"`
+	// UsageClass is the class whose usage is compared to the bands, Class when empty.
+	UsageClass string `json:"usageClass,omitempty"`
+	// MinReplicas and MaxReplicas bound the target of the bands and of the policy factors.
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// Bands are evaluated in order, the first one matching the usage sets the target.
+	// Without a match the class keeps its previous target.
+	Bands []Band `json:"bands,omitempty"`
+}
+
+// Config is the content of a scaling rules file.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// rule is a validated Rule.
+type rule struct {
+	Rule
+	usageClass policy.Class
+}
+
+// RuleSet is a validated set of rules, at most one per energy status and importance class.
+type RuleSet struct {
+	rules map[policy.Status]map[policy.Class]rule
+}
+
+// Compile validates rules against limits.
+func Compile(rules []Rule, limits policy.Limits) (*RuleSet, error) {
+	set := &RuleSet{rules: make(map[policy.Status]map[policy.Class]rule)}
+	for i, r := range rules {
+		status, err := policy.ParseStatus(r.Status)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		class, err := policy.ParseClass(r.Class)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		compiled := rule{Rule: r, usageClass: class}
+		if r.UsageClass != "" {
+			if compiled.usageClass, err = policy.ParseClass(r.UsageClass); err != nil {
+				return nil, fmt.Errorf("rule %d: usageClass: %v", i, err)
+			}
+		}
+		if err := validateRule(r, limits); err != nil {
+			return nil, fmt.Errorf("rule %d (%s/%s): %v", i, status, class, err)
+		}
+		if set.rules[status] == nil {
+			set.rules[status] = make(map[policy.Class]rule)
+		}
+		if _, ok := set.rules[status][class]; ok {
+			return nil, fmt.Errorf("rule %d: duplicate rule for %s/%s", i, status, class)
+		}
+		set.rules[status][class] = compiled
+	}
+	return set, nil
+}
+
+func validateRule(r Rule, limits policy.Limits) error {
+	if r.MinReplicas != nil && (*r.MinReplicas < 0 || *r.MinReplicas > limits.MaxReplicas) {
+		return fmt.Errorf("minReplicas must be between 0 and %d", limits.MaxReplicas)
+	}
+	if r.MaxReplicas != nil && (*r.MaxReplicas < 0 || *r.MaxReplicas > limits.MaxReplicas) {
+		return fmt.Errorf("maxReplicas must be between 0 and %d", limits.MaxReplicas)
+	}
+	if r.MinReplicas != nil && r.MaxReplicas != nil && *r.MinReplicas > *r.MaxReplicas {
+		return fmt.Errorf("minReplicas %d is above maxReplicas %d", *r.MinReplicas, *r.MaxReplicas)
+	}
+	for i, band := range r.Bands {
+		if band.Replicas < 0 || band.Replicas > limits.MaxReplicas {
+			return fmt.Errorf("band %d: replicas must be between 0 and %d", i, limits.MaxReplicas)
+		}
+		if band.Action != "" && band.Action != ActionAdd && band.Action != ActionSubtract {
+			return fmt.Errorf("band %d: unknown action %q, must be %s, %s or empty", i, band.Action, ActionAdd, ActionSubtract)
+		}
+	}
+	return nil
+}
+
+// Parse reads and compiles the rules of a YAML or JSON document.
+func Parse(content []byte, limits policy.Limits) (*RuleSet, error) {
+	var config Config
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, err
+	}
+	return Compile(config.Rules, limits)
+}
+
+// Targets are the replica targets of every status and class picked by the bands, with the
+// action of the band that matched.
+type Targets struct {
+	Factors policy.Factors
+	Actions map[policy.Status]map[policy.Class]string
+}
+
+// Action returns the action of the band that set the target of a class, empty when none did
+// or t is nil.
+func (t *Targets) Action(status policy.Status, class policy.Class) string {
+	if t == nil {
+		return ""
+	}
+	return t.Actions[status][class]
+}
+
+// Evaluate matches the average usage of the pods of every class against the bands.
+// Classes without a matching band are left out of the result.
+func (s *RuleSet) Evaluate(usage map[policy.Class]metrics.Usage) *Targets {
+	targets := &Targets{Factors: make(policy.Factors), Actions: make(map[policy.Status]map[policy.Class]string)}
+	for status, classes := range s.rules {
+		for class, r := range classes {
+			band, ok := r.match(usage[r.usageClass])
+			if !ok {
+				continue
+			}
+			if targets.Factors[status] == nil {
+				targets.Factors[status] = make(map[policy.Class]int32)
+				targets.Actions[status] = make(map[policy.Class]string)
+			}
+			targets.Factors[status][class] = r.clamp(band.Replicas)
+			targets.Actions[status][class] = band.Action
+		}
+	}
+	return targets
+}
+
+// Clamp bounds replicas to the minReplicas and maxReplicas of the rule of a status and class.
+func (s *RuleSet) Clamp(status policy.Status, class policy.Class, replicas int32) int32 {
+	r, ok := s.rules[status][class]
+	if !ok {
+		return replicas
+	}
+	return r.clamp(replicas)
+}
+
+func (r *rule) clamp(replicas int32) int32 {
+	if r.MinReplicas != nil && replicas < *r.MinReplicas {
+		return *r.MinReplicas
+	}
+	if r.MaxReplicas != nil && replicas > *r.MaxReplicas {
+		return *r.MaxReplicas
+	}
+	return replicas
+}
+
+// match returns the first band whose bounds hold for usage.
+func (r *rule) match(usage metrics.Usage) (Band, bool) {
+	for _, band := range r.Bands {
+		if band.CPUAbove != nil && usage.CPU <= band.CPUAbove.ScaledValue(resource.Nano) {
+			continue
+		}
+		if band.CPUBelow != nil && usage.CPU >= band.CPUBelow.ScaledValue(resource.Nano) {
+			continue
+		}
+		if band.MemoryAbove != nil && usage.Memory <= band.MemoryAbove.Value() {
+			continue
+		}
+		if band.MemoryBelow != nil && usage.Memory >= band.MemoryBelow.Value() {
+			continue
+		}
+		return band, true
+	}
+	return Band{}, false
+}
+
+// Default returns the rules the controller used before rules were configurable: the CPU usage
+// of the High class above scaleDownCPU nanocores scales the other classes down, below
+// scaleUpCPU scales every class up, and anything in between scales up to the middle targets.
+func Default(scaleDownCPU, scaleUpCPU int64) *RuleSet {
+	targets := map[policy.Status]map[policy.Class][3]int32{
+		policy.Green: {policy.High: {10, 10, 10}, policy.Medium: {3, 10, 6}, policy.Low: {3, 10, 6}},
+		policy.Brown: {policy.High: {8, 8, 8}, policy.Medium: {2, 8, 4}, policy.Low: {2, 8, 4}},
+		policy.Black: {policy.High: {3, 3, 3}, policy.Medium: {1, 3, 2}, policy.Low: {1, 3, 2}},
+	}
+	down := resource.NewScaledQuantity(scaleDownCPU, resource.Nano)
+	up := resource.NewScaledQuantity(scaleUpCPU, resource.Nano)
+
+	var rules []Rule
+	for _, status := range policy.Statuses {
+		for _, class := range policy.Classes {
+			t := targets[status][class]
+			rules = append(rules, Rule{
+				Status:     string(status),
+				Class:      string(class),
+				UsageClass: string(policy.High),
+				Bands: []Band{
+					{CPUAbove: down, Replicas: t[0], Action: ActionSubtract},
+					{CPUBelow: up, Replicas: t[1], Action: ActionAdd},
+					{Replicas: t[2], Action: ActionAdd},
+				},
+			})
+		}
+	}
+	set, err := Compile(rules, policy.Limits{MaxReplicas: policy.DefaultMaxReplicas})
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
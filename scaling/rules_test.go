@@ -0,0 +1,208 @@
+package scaling
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kube-flux/kube-flux/metrics"
+	"github.com/kube-flux/kube-flux/policy"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func quantity(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestRuleSetEvaluate(t *testing.T) {
+	rules := []Rule{{
+		Status: "Green",
+		Class:  "High",
+		Bands: []Band{
+			{CPUAbove: quantity("500m"), Replicas: 6, Action: ActionAdd},
+			{MemoryAbove: quantity("1Gi"), Replicas: 5, Action: ActionAdd},
+			{CPUBelow: quantity("100m"), MemoryBelow: quantity("256Mi"), Replicas: 1, Action: ActionSubtract},
+			{CPUAbove: quantity("100m"), CPUBelow: quantity("300m"), Replicas: 3},
+		},
+	}}
+	set, err := Compile(rules, policy.DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		usage       metrics.Usage
+		replicas    int32
+		action      string
+		wantMatched bool
+	}{
+		{name: "cpu above", usage: metrics.Usage{CPU: 600000000}, replicas: 6, action: ActionAdd, wantMatched: true},
+		{name: "first match wins", usage: metrics.Usage{CPU: 600000000, Memory: 2 << 30}, replicas: 6, action: ActionAdd, wantMatched: true},
+		{name: "memory above", usage: metrics.Usage{CPU: 200000000, Memory: 2 << 30}, replicas: 5, action: ActionAdd, wantMatched: true},
+		{name: "cpu and memory below", usage: metrics.Usage{CPU: 50000000, Memory: 128 << 20}, replicas: 1, action: ActionSubtract, wantMatched: true},
+		{name: "cpu between", usage: metrics.Usage{CPU: 200000000, Memory: 512 << 20}, replicas: 3, wantMatched: true},
+		{name: "bounds are exclusive", usage: metrics.Usage{CPU: 500000000, Memory: 512 << 20}},
+		{name: "no match", usage: metrics.Usage{CPU: 50000000, Memory: 512 << 20}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			targets := set.Evaluate(map[policy.Class]metrics.Usage{policy.High: test.usage})
+			replicas, matched := targets.Factors[policy.Green][policy.High]
+			if matched != test.wantMatched || replicas != test.replicas {
+				t.Errorf("got %d replicas, matched %t, want %d, matched %t", replicas, matched, test.replicas, test.wantMatched)
+			}
+			if action := targets.Action(policy.Green, policy.High); action != test.action {
+				t.Errorf("got action %q, want %q", action, test.action)
+			}
+			if !matched && len(targets.Factors) != 0 {
+				t.Errorf("got factors %v, want the class left out", targets.Factors)
+			}
+		})
+	}
+}
+
+func TestRuleSetUsageClassAndClamp(t *testing.T) {
+	rules := []Rule{{
+		Status:      "Black",
+		Class:       "Low",
+		UsageClass:  "High",
+		MinReplicas: int32Ptr(2),
+		MaxReplicas: int32Ptr(4),
+		Bands: []Band{
+			{CPUAbove: quantity("1"), Replicas: 1},
+			{Replicas: 9},
+		},
+	}}
+	set, err := Compile(rules, policy.DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		usage map[policy.Class]metrics.Usage
+		want  int32
+	}{
+		{name: "clamped to min", usage: map[policy.Class]metrics.Usage{policy.High: {CPU: 2000000000}}, want: 2},
+		{name: "clamped to max", usage: map[policy.Class]metrics.Usage{policy.High: {CPU: 100000000}}, want: 4},
+		{name: "usage of another class", usage: map[policy.Class]metrics.Usage{policy.Low: {CPU: 2000000000}}, want: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			targets := set.Evaluate(test.usage)
+			if got := targets.Factors[policy.Black][policy.Low]; got != test.want {
+				t.Errorf("got %d replicas, want %d", got, test.want)
+			}
+		})
+	}
+
+	if got := set.Clamp(policy.Black, policy.Low, 10); got != 4 {
+		t.Errorf("Clamp(10) = %d, want 4", got)
+	}
+	if got := set.Clamp(policy.Green, policy.Low, 10); got != 10 {
+		t.Errorf("Clamp(10) without a rule = %d, want 10", got)
+	}
+	var targets *Targets
+	if action := targets.Action(policy.Black, policy.Low); action != "" {
+		t.Errorf("got action %q of nil targets, want none", action)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// wantErr is part of the expected error, empty for none
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+rules:
+- status: green
+  class: high
+  minReplicas: 1
+  bands:
+  - cpuAbove: 250m
+    replicas: 5
+    action: add
+- status: Green
+  class: Low
+  usageClass: High
+  bands:
+  - replicas: 2
+`,
+		},
+		{name: "empty", content: `rules: []`},
+		{name: "unknown field", content: "rules:\n- status: Green\n  class: High\n  band: []\n", wantErr: "unknown field"},
+		{name: "unknown status", content: "rules:\n- status: Blue\n  class: High\n", wantErr: "rule 0"},
+		{name: "unknown class", content: "rules:\n- status: Green\n  class: Urgent\n", wantErr: "rule 0"},
+		{name: "unknown usage class", content: "rules:\n- status: Green\n  class: High\n  usageClass: Urgent\n", wantErr: "usageClass"},
+		{name: "duplicate", content: "rules:\n- status: Green\n  class: High\n- status: green\n  class: high\n", wantErr: "duplicate rule for Green/High"},
+		{name: "negative min", content: "rules:\n- status: Green\n  class: High\n  minReplicas: -1\n", wantErr: "minReplicas must be between"},
+		{name: "max above limit", content: "rules:\n- status: Green\n  class: High\n  maxReplicas: 11\n", wantErr: "maxReplicas must be between"},
+		{name: "min above max", content: "rules:\n- status: Green\n  class: High\n  minReplicas: 3\n  maxReplicas: 2\n", wantErr: "above maxReplicas"},
+		{name: "band replicas above limit", content: "rules:\n- status: Green\n  class: High\n  bands:\n  - replicas: 11\n", wantErr: "band 0"},
+		{name: "unknown action", content: "rules:\n- status: Green\n  class: High\n  bands:\n  - replicas: 1\n    action: double\n", wantErr: "unknown action"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.content), policy.Limits{MaxReplicas: 10})
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("got %v, want no error", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Errorf("got %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	set := Default(800000000, 200000000)
+	tests := []struct {
+		name string
+		cpu  int64
+		want policy.Factors
+	}{
+		{
+			name: "busy",
+			cpu:  900000000,
+			want: policy.Factors{
+				policy.Green: {policy.High: 10, policy.Medium: 3, policy.Low: 3},
+				policy.Brown: {policy.High: 8, policy.Medium: 2, policy.Low: 2},
+				policy.Black: {policy.High: 3, policy.Medium: 1, policy.Low: 1},
+			},
+		},
+		{
+			name: "idle",
+			cpu:  100000000,
+			want: policy.Factors{
+				policy.Green: {policy.High: 10, policy.Medium: 10, policy.Low: 10},
+				policy.Brown: {policy.High: 8, policy.Medium: 8, policy.Low: 8},
+				policy.Black: {policy.High: 3, policy.Medium: 3, policy.Low: 3},
+			},
+		},
+		{
+			name: "between",
+			cpu:  500000000,
+			want: policy.Factors{
+				policy.Green: {policy.High: 10, policy.Medium: 6, policy.Low: 6},
+				policy.Brown: {policy.High: 8, policy.Medium: 4, policy.Low: 4},
+				policy.Black: {policy.High: 3, policy.Medium: 2, policy.Low: 2},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			targets := set.Evaluate(map[policy.Class]metrics.Usage{policy.High: {CPU: test.cpu}})
+			if !reflect.DeepEqual(targets.Factors, test.want) {
+				t.Errorf("got %v, want %v", targets.Factors, test.want)
+			}
+		})
+	}
+}
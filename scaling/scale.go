@@ -0,0 +1,31 @@
+package scaling
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// SetReplicas sets the replicas of a Deployment through its scale subresource, so that no other
+// field of the Deployment is overwritten. The scale is read again and the update retried when it
+// conflicts with a concurrent change. It returns the replicas the Deployment had before.
+func SetReplicas(ctx context.Context, client kubernetes.Interface, namespace, name string, replicas int32) (int32, error) {
+	var previous int32
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scale, err := client.AppsV1().Deployments(namespace).GetScale(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		previous = scale.Spec.Replicas
+		if scale.Spec.Replicas == replicas {
+			return nil
+		}
+		// the resource version of the scale makes the update fail on a concurrent change
+		scale.Spec.Replicas = replicas
+		_, err = client.AppsV1().Deployments(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+		return err
+	})
+	return previous, err
+}
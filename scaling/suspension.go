@@ -0,0 +1,113 @@
+package scaling
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/kube-flux/kube-flux/policy"
+)
+
+// Suspension selects the batch workloads suspended under constrained energy statuses: fewer
+// replicas only help long-running services, CronJobs and Jobs have to stop running instead.
+type Suspension struct {
+	// Classes are the importance classes whose CronJobs are suspended, none by default.
+	Classes ClassList
+	// Statuses are the energy statuses suspending them, Brown and Black by default.
+	Statuses StatusList
+	// Jobs also pauses the running Jobs of the classes.
+	Jobs bool
+}
+
+// AddFlags registers the suspension on a flag set.
+func (s *Suspension) AddFlags(fs *flag.FlagSet) {
+	s.Statuses = StatusList{policy.Brown, policy.Black}
+	fs.Var(&s.Classes, "suspend-classes", "Comma-separated importance classes whose CronJobs are suspended under -suspend-statuses, e.g. Low")
+	fs.Var(&s.Statuses, "suspend-statuses", "Comma-separated energy statuses suspending the CronJobs of -suspend-classes")
+	fs.BoolVar(&s.Jobs, "suspend-jobs", false, "Also pause the running Jobs of -suspend-classes through spec.suspend, or their parallelism on clusters without Job suspension")
+}
+
+// Enabled reports whether any class is suspended.
+func (s *Suspension) Enabled() bool {
+	return len(s.Classes) != 0
+}
+
+// Suspends reports whether the batch workloads of class are suspended under status.
+func (s *Suspension) Suspends(status policy.Status, class policy.Class) bool {
+	return s.Classes.Contains(class) && s.Statuses.Contains(status)
+}
+
+// ClassList is a comma-separated list of importance classes, usable as a flag.
+type ClassList []policy.Class
+
+func (l *ClassList) String() string {
+	names := make([]string, 0, len(*l))
+	for _, class := range *l {
+		names = append(names, string(class))
+	}
+	return strings.Join(names, ",")
+}
+
+// Set parses the list, replacing the current classes.
+func (l *ClassList) Set(value string) error {
+	classes := ClassList{}
+	for _, name := range strings.Split(value, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		class, err := policy.ParseClass(name)
+		if err != nil {
+			return err
+		}
+		classes = append(classes, class)
+	}
+	*l = classes
+	return nil
+}
+
+// Contains reports whether class is in the list.
+func (l ClassList) Contains(class policy.Class) bool {
+	for _, c := range l {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusList is a comma-separated list of energy statuses, usable as a flag.
+type StatusList []policy.Status
+
+func (l *StatusList) String() string {
+	names := make([]string, 0, len(*l))
+	for _, status := range *l {
+		names = append(names, string(status))
+	}
+	return strings.Join(names, ",")
+}
+
+// Set parses the list, replacing the current statuses.
+func (l *StatusList) Set(value string) error {
+	statuses := StatusList{}
+	for _, name := range strings.Split(value, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		status, err := policy.ParseStatus(name)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+	}
+	*l = statuses
+	return nil
+}
+
+// Contains reports whether status is in the list.
+func (l StatusList) Contains(status policy.Status) bool {
+	for _, s := range l {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package scaling
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/scale"
+	"k8s.io/client-go/util/retry"
+)
+
+// DefaultTargetKinds are the kinds scaled when no other kinds are configured.
+const DefaultTargetKinds = "apps/v1/Deployment"
+
+// ParseTargetKinds parses a comma-separated list of apiVersion/Kind, e.g.
+// "apps/v1/Deployment,apps/v1/StatefulSet,argoproj.io/v1alpha1/Rollout".
+func ParseTargetKinds(value string) ([]schema.GroupVersionKind, error) {
+	var kinds []schema.GroupVersionKind
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "/")
+		if i <= 0 || i == len(item)-1 {
+			return nil, fmt.Errorf("invalid kind %q, expected apiVersion/Kind", item)
+		}
+		gv, err := schema.ParseGroupVersion(item[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid kind %q: %v", item, err)
+		}
+		kinds = append(kinds, gv.WithKind(item[i+1:]))
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("no kind to scale")
+	}
+	return kinds, nil
+}
+
+// ResolveTargetKinds maps kinds to the resources serving them. Kinds the cluster doesn't
+// serve, e.g. Rollouts without Argo Rollouts installed, are logged and left out.
+func ResolveTargetKinds(mapper meta.RESTMapper, kinds []schema.GroupVersionKind) ([]schema.GroupVersionResource, error) {
+	var resources []schema.GroupVersionResource
+	for _, kind := range kinds {
+		mapping, err := mapper.RESTMapping(kind.GroupKind(), kind.Version)
+		if meta.IsNoMatchError(err) {
+			log.Println("func", "ResolveTargetKinds", "Kind", kind, "isn't served by the cluster, skipping it")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			return nil, fmt.Errorf("kind %s isn't namespaced", kind)
+		}
+		resources = append(resources, mapping.Resource)
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("none of the kinds to scale is served by the cluster")
+	}
+	return resources, nil
+}
+
+// Workload is an object of a resource with a scale subresource, as read by a dynamic informer.
+// Deployments, StatefulSets, ReplicaSets and Argo Rollouts all keep their replicas, selector and
+// pod template in the same spec fields.
+type Workload struct {
+	Resource schema.GroupVersionResource
+	*unstructured.Unstructured
+}
+
+// NewWorkload wraps an object of resource, which must be *unstructured.Unstructured.
+func NewWorkload(resource schema.GroupVersionResource, obj runtime.Object) (*Workload, bool) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, false
+	}
+	return &Workload{Resource: resource, Unstructured: u}, true
+}
+
+// Key identifies the workload across resources, e.g. "deployments.apps/default/nginx".
+func (w *Workload) Key() string {
+	return WorkloadKey(w.Resource, w.GetNamespace(), w.GetName())
+}
+
+// WorkloadKey builds the Key of a workload.
+func WorkloadKey(resource schema.GroupVersionResource, namespace, name string) string {
+	return resource.GroupResource().String() + "/" + namespace + "/" + name
+}
+
+// Replicas returns spec.replicas, defaulting to 1 like the API server.
+func (w *Workload) Replicas() int32 {
+	replicas, found, err := unstructured.NestedInt64(w.Object, "spec", "replicas")
+	if err != nil || !found {
+		return 1
+	}
+	return int32(replicas)
+}
+
+// Selector returns the label selector of the pods of the workload.
+func (w *Workload) Selector() (labels.Selector, error) {
+	fields, found, err := unstructured.NestedMap(w.Object, "spec", "selector")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s has no spec.selector", w.Key())
+	}
+	var selector metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fields, &selector); err != nil {
+		return nil, err
+	}
+	return metav1.LabelSelectorAsSelector(&selector)
+}
+
+// TemplateAnnotations returns the annotations of the pod template of the workload.
+func (w *Workload) TemplateAnnotations() map[string]string {
+	annotations, _, _ := unstructured.NestedStringMap(w.Object, "spec", "template", "metadata", "annotations")
+	return annotations
+}
+
+// TemplateLabels returns the labels of the pod template of the workload.
+func (w *Workload) TemplateLabels() map[string]string {
+	templateLabels, _, _ := unstructured.NestedStringMap(w.Object, "spec", "template", "metadata", "labels")
+	return templateLabels
+}
+
+// Managed reports whether the workload is scaled on its own, i.e. has no controller owner
+// scaling it, like the ReplicaSets of a Deployment or a Rollout.
+func (w *Workload) Managed() bool {
+	return metav1.GetControllerOf(w) == nil
+}
+
+// SetScale sets the replicas of any resource with a scale subresource, with the same conflict
+// handling as SetReplicas. It returns the replicas the workload had before.
+func SetScale(ctx context.Context, scales scale.ScalesGetter, resource schema.GroupResource, namespace, name string, replicas int32) (int32, error) {
+	var previous int32
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := scales.Scales(namespace).Get(ctx, resource, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		previous = s.Spec.Replicas
+		if s.Spec.Replicas == replicas {
+			return nil
+		}
+		s.Spec.Replicas = replicas
+		_, err = scales.Scales(namespace).Update(ctx, resource, s, metav1.UpdateOptions{})
+		return err
+	})
+	return previous, err
+}